@@ -0,0 +1,177 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/control"
+	"github.com/rholder/i3status-title-on-bar/pkg/i3"
+)
+
+// Counters of what the running instance did, dumped by the stats command of
+// the control socket.
+type stats struct {
+	started       time.Time
+	statusLines   atomic.Int64
+	windowChanges atomic.Int64
+	refreshes     atomic.Int64
+	reloads       atomic.Int64
+	requests      atomic.Int64
+}
+
+// Counts the status lines written through it, which are every line after the
+// version header and the start of the infinite array. Each line is expected in
+// a write of its own.
+type statusLineCounter struct {
+	writer io.Writer
+	lines  *atomic.Int64
+	header int
+}
+
+func (counter *statusLineCounter) Write(p []byte) (int, error) {
+	if bytes.HasSuffix(p, []byte("\n")) {
+		if counter.header < 2 {
+			counter.header++
+		} else {
+			counter.lines.Add(1)
+		}
+	}
+	return counter.writer.Write(p)
+}
+
+// Answers the requests arriving on the control socket, changing what the
+// running instance shows.
+type controller struct {
+	live              *i3.LiveOptions
+	sources           []i3.TitleSource
+	stats             *stats
+	titleChangeEvents chan interface{}
+}
+
+// Handle a request from the control socket, sending a change to be sampled
+// for everything that changes what's shown so it comes right away.
+func (controller *controller) handle(request control.Request) control.Response {
+	controller.stats.requests.Add(1)
+	options := controller.live.Load()
+	response := control.Response{OK: true}
+	switch request.Command {
+	case control.Window:
+		response.Windows = controller.windows()
+		return response
+
+	case control.Stats:
+		response.Stats = controller.statsInfo()
+		return response
+
+	case control.Override:
+		if request.Seconds < 0 {
+			return control.Failed(fmt.Errorf("invalid seconds %v, expected a positive number", request.Seconds))
+		}
+		override := &i3.Override{Text: request.Text}
+		if request.Seconds > 0 {
+			duration := time.Duration(request.Seconds * float64(time.Second))
+			override.Until = time.Now().Add(duration)
+			// show the titles again as soon as it expires
+			time.AfterFunc(duration, func() {
+				controller.titleChangeEvents <- control.Override
+			})
+		}
+		options.Override.Store(override)
+
+	case control.Privacy:
+		private := false
+		if request.Private != nil {
+			private = *request.Private
+			options.Private.Store(private)
+		} else {
+			private = togglePrivacy(options.Private)
+		}
+		response.Private = &private
+
+	case control.Color:
+		if request.Color == "" {
+			return control.Failed(errors.New("missing the color to change to"))
+		}
+		controller.live.Update(func(options *i3.Options) {
+			options.Color = request.Color
+		})
+
+	case control.Refresh:
+
+	default:
+		return control.Failed(fmt.Errorf("unknown command %q, expected one of %s",
+			request.Command, strings.Join(control.Commands, ", ")))
+	}
+	controller.titleChangeEvents <- request.Command
+	return response
+}
+
+// Describe the active window of each title source, leaving out what would
+// identify a window hidden by privacy mode or one of the masks.
+func (controller *controller) windows() []control.WindowInfo {
+	options := controller.live.Load()
+	var windows []control.WindowInfo
+	for _, source := range controller.sources {
+		info, err := source.API.ActiveWindow()
+		if err != nil {
+			windows = append(windows, control.WindowInfo{Source: source.Instance, Error: err.Error()})
+			continue
+		}
+		if text, masked := i3.MaskedTitle(info, options); masked {
+			windows = append(windows, control.WindowInfo{Source: source.Instance, ID: info.ID, Title: text})
+			continue
+		}
+		windows = append(windows, control.WindowInfo{
+			Source:    source.Instance,
+			ID:        info.ID,
+			Title:     info.Title,
+			Class:     info.Class,
+			Instance:  info.Instance,
+			Role:      info.Role,
+			Workspace: info.Workspace,
+			PID:       info.PID,
+			Floating:  info.Floating,
+			Urgent:    info.Urgent,
+			States:    info.States,
+		})
+	}
+	return windows
+}
+
+// Take a snapshot of the counters along with what's currently shown.
+func (controller *controller) statsInfo() *control.StatsInfo {
+	options := controller.live.Load()
+	info := &control.StatsInfo{
+		Started:       controller.stats.started,
+		StatusLines:   controller.stats.statusLines.Load(),
+		WindowChanges: controller.stats.windowChanges.Load(),
+		Refreshes:     controller.stats.refreshes.Load(),
+		Reloads:       controller.stats.reloads.Load(),
+		Requests:      controller.stats.requests.Load(),
+		Color:         options.Color,
+		Private:       options.Private.Load(),
+	}
+	if override := options.Override.Load(); override != nil && (override.Until.IsZero() || time.Now().Before(override.Until)) {
+		info.Override = override.Text
+	}
+	return info
+}
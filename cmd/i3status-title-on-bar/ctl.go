@@ -0,0 +1,153 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"slices"
+	"strings"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/control"
+)
+
+const ctlHelpText = `Usage: i3status-title-on-bar ctl [OPTIONS...] COMMAND [ARGUMENT]
+
+  Send a command to the running i3status-title-on-bar over its control socket
+  and print the JSON response, handy for keybindings and scripts.
+
+Commands:
+  window                   Print the active window of each title source
+  override [text]          Show this text instead of every title, or clear it when empty
+  privacy [on|off]         Toggle privacy mode, or switch it on or off
+  color [i3_color_code]    Change the text color of the titles until the next reload
+  refresh                  Make i3status print a new status line right away
+  stats                    Print counters of what the running instance did
+
+Options:
+  --socket [path]          Control socket of the running instance (Defaults to
+                           $XDG_RUNTIME_DIR/i3status-title-on-bar.sock)
+  --for [seconds]          Clear the override text after this long
+  --help                   Print this help text and exit
+
+Examples:
+  i3status-title-on-bar ctl window
+  i3status-title-on-bar ctl override 'back in 5' --for 300
+  i3status-title-on-bar ctl privacy on
+  bindsym $mod+p exec --no-startup-id i3status-title-on-bar ctl privacy`
+
+// Run the ctl subcommand with the given arguments, returning the exit code.
+func runCtl(stdout io.Writer, stderr io.Writer, args []string) int {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		socket    = fs.String("socket", control.DefaultPath(), "Control socket of the running instance")
+		seconds   = fs.Float64("for", 0, "Clear the override text after this long")
+		printHelp = fs.Bool("help", false, "Print additional help text and exit")
+	)
+	// disable default output
+	fs.SetOutput(ioutil.Discard)
+	positional, err := parseInterspersed(fs, args[1:])
+	var request control.Request
+	if err == nil && !*printHelp {
+		request, err = newControlRequest(positional, *seconds)
+	}
+	if err != nil {
+		fmt.Fprintln(stdout, err.Error()+"\n")
+		fmt.Fprintln(stdout, ctlHelpText)
+		return BadConfigErrorCode
+	}
+	if *printHelp {
+		fmt.Fprintln(stdout, ctlHelpText)
+		return PrintErrorCode
+	}
+
+	response, err := control.Send(*socket, request)
+	if err != nil {
+		if response.Error == "" {
+			fmt.Fprintf(stderr, "No running instance answered at %s: %s\n", *socket, err)
+		} else {
+			fmt.Fprintln(stderr, err)
+		}
+		return ControlErrorCode
+	}
+	line, err := json.Marshal(response)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ControlErrorCode
+	}
+	fmt.Fprintf(stdout, "%s\n", line)
+	return 0
+}
+
+// Build the request for the given command and its argument.
+func newControlRequest(positional []string, seconds float64) (control.Request, error) {
+	if len(positional) == 0 {
+		return control.Request{}, fmt.Errorf("missing the command to send")
+	}
+	request := control.Request{Command: positional[0]}
+	if !slices.Contains(control.Commands, request.Command) {
+		return request, fmt.Errorf("unknown command %q, expected one of %s", request.Command, strings.Join(control.Commands, ", "))
+	}
+
+	argument := strings.Join(positional[1:], " ")
+	if argument != "" && request.Command != control.Override && request.Command != control.Privacy && request.Command != control.Color {
+		return request, fmt.Errorf("%s takes no argument", request.Command)
+	}
+	if seconds != 0 && request.Command != control.Override {
+		return request, fmt.Errorf("--for only applies to override")
+	}
+	switch request.Command {
+	case control.Override:
+		request.Text, request.Seconds = argument, seconds
+	case control.Color:
+		if argument == "" {
+			return request, fmt.Errorf("missing the color to change to")
+		}
+		request.Color = argument
+	case control.Privacy:
+		switch argument {
+		case "":
+		case "on", "off":
+			private := argument == "on"
+			request.Private = &private
+		default:
+			return request, fmt.Errorf("expected on or off for privacy, got %q", argument)
+		}
+	}
+	return request, nil
+}
+
+// Parse the given arguments with options allowed anywhere among them, not
+// only before the first positional one, returning the positional ones.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		// everything after -- is positional
+		if len(args) > fs.NArg() && args[len(args)-fs.NArg()-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/control"
+	"github.com/rholder/i3status-title-on-bar/pkg/i3"
+	"github.com/rholder/i3status-title-on-bar/pkg/rules"
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// Serve a controller for a fake window on a socket of its own, returning the
+// socket and the controller.
+func serveController(t *testing.T) (string, *controller) {
+	t.Helper()
+	windowAPI, err := window.NewFake(strings.NewReader("focus 7 Alacritty vim main.go\nstate 7 fullscreen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	options := i3.Options{Color: "#00FF00", Private: &atomic.Bool{}, Override: &atomic.Pointer[i3.Override]{}}
+	controller := &controller{
+		live:              i3.NewLiveOptions(options),
+		sources:           []i3.TitleSource{{API: windowAPI}},
+		stats:             &stats{started: time.Now()},
+		titleChangeEvents: make(chan interface{}, 10),
+	}
+	path := filepath.Join(t.TempDir(), control.SocketName)
+	listener, err := control.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go control.Serve(listener, controller.handle, control.DefaultIdleTimeout, func(error) {})
+	return path, controller
+}
+
+func runCtlLine(t *testing.T, socket string, args ...string) string {
+	t.Helper()
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	args = append([]string{"ctl", "--socket", socket}, args...)
+	if code := runCtl(&stdout, &stderr, args); code != 0 {
+		t.Fatalf("Expected success for %v, got %d: %s", args, code, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String())
+}
+
+func TestCtl(t *testing.T) {
+	socket, controller := serveController(t)
+
+	expected := `{"ok":true,"windows":[{"id":7,"title":"vim main.go","class":"Alacritty","states":["fullscreen"]}]}`
+	if line := runCtlLine(t, socket, "window"); line != expected {
+		t.Fatalf("Expected %s, got %s", expected, line)
+	}
+
+	if line := runCtlLine(t, socket, "override", "back", "in", "5", "--for", "300"); line != `{"ok":true}` {
+		t.Fatalf("Unexpected response %s", line)
+	}
+	override := controller.live.Load().Override.Load()
+	if override.Text != "back in 5" || time.Until(override.Until) < 299*time.Second {
+		t.Fatalf("Unexpected override %+v", override)
+	}
+
+	if line := runCtlLine(t, socket, "privacy"); line != `{"ok":true,"private":true}` {
+		t.Fatalf("Expected privacy mode on, got %s", line)
+	}
+	if line := runCtlLine(t, socket, "privacy", "off"); line != `{"ok":true,"private":false}` {
+		t.Fatalf("Expected privacy mode off, got %s", line)
+	}
+
+	runCtlLine(t, socket, "color", "#FF0000")
+	runCtlLine(t, socket, "refresh")
+	if color := controller.live.Load().Color; color != "#FF0000" {
+		t.Fatalf("Expected the changed color, got %s", color)
+	}
+
+	// every change is sent to be sampled so it shows right away
+	var events []string
+	for len(controller.titleChangeEvents) > 0 {
+		events = append(events, (<-controller.titleChangeEvents).(string))
+	}
+	if strings.Join(events, ",") != "override,privacy,privacy,color,refresh" {
+		t.Fatalf("Unexpected changes %v", events)
+	}
+
+	controller.stats.statusLines.Add(3)
+	line := runCtlLine(t, socket, "stats")
+	for _, expected := range []string{`"status_lines":3`, `"requests":7`, `"color":"#FF0000"`, `"override":"back in 5"`} {
+		if !strings.Contains(line, expected) {
+			t.Fatalf("Expected %s in %s", expected, line)
+		}
+	}
+}
+
+func TestCtlWindowMasked(t *testing.T) {
+	socket, controller := serveController(t)
+	controller.live.Update(func(options *i3.Options) {
+		options.Masks = rules.Masks{{Class: "alacritty", Text: "terminal"}}
+	})
+	expected := `{"ok":true,"windows":[{"id":7,"title":"terminal"}]}`
+	if line := runCtlLine(t, socket, "window"); line != expected {
+		t.Fatalf("Expected %s, got %s", expected, line)
+	}
+
+	controller.live.Update(func(options *i3.Options) {
+		options.Masks = nil
+	})
+	runCtlLine(t, socket, "privacy", "on")
+	expected = `{"ok":true,"windows":[{"id":7,"title":"(hidden)"}]}`
+	if line := runCtlLine(t, socket, "window"); line != expected {
+		t.Fatalf("Expected %s, got %s", expected, line)
+	}
+}
+
+func TestCtlErrors(t *testing.T) {
+	socket, controller := serveController(t)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := runCtl(&stdout, &stderr, []string{"ctl", "--socket", filepath.Join(t.TempDir(), "missing.sock"), "refresh"})
+	if code != ControlErrorCode || !strings.HasPrefix(stderr.String(), "No running instance answered at ") {
+		t.Fatalf("Expected no running instance, got %d: %s", code, stderr.String())
+	}
+
+	// the instance rejects a negative duration
+	stderr.Reset()
+	code = runCtl(&stdout, &stderr, []string{"ctl", "--socket", socket, "override", "--for", "-1", "x"})
+	if code != ControlErrorCode || !strings.HasPrefix(stderr.String(), "invalid seconds -1") {
+		t.Fatalf("Expected invalid seconds, got %d: %s", code, stderr.String())
+	}
+	if response := controller.handle(control.Request{Command: "potato"}); response.OK || !strings.HasPrefix(response.Error, `unknown command "potato"`) {
+		t.Fatalf("Expected unknown command, got %+v", response)
+	}
+
+	for _, args := range [][]string{
+		{"ctl"},
+		{"ctl", "potato"},
+		{"ctl", "refresh", "now"},
+		{"ctl", "color"},
+		{"ctl", "privacy", "maybe"},
+		{"ctl", "--for", "3", "refresh"},
+		{"ctl", "--potato"},
+	} {
+		if code := runCtl(ioutil.Discard, ioutil.Discard, args); code != BadConfigErrorCode {
+			t.Fatalf("Expected bad config code for %v, got %d", args, code)
+		}
+	}
+	if code := runCtl(ioutil.Discard, ioutil.Discard, []string{"ctl", "--help"}); code != PrintErrorCode {
+		t.Fatalf("Expected print code for help, got %d", code)
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	seconds := fs.Float64("for", 0, "")
+	positional, err := parseInterspersed(fs, []string{"override", "--for", "5", "brb", "--", "--for"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *seconds != 5 || strings.Join(positional, "|") != "override|brb|--for" {
+		t.Fatalf("Unexpected positional arguments %q", positional)
+	}
+}
+
+func TestStatusLineCounter(t *testing.T) {
+	var lines atomic.Int64
+	counter := &statusLineCounter{writer: ioutil.Discard, lines: &lines}
+	for _, line := range []string{"{\"version\":1}\n", "[\n", "[{}]\n", ",[{}]\n"} {
+		counter.Write([]byte(line))
+	}
+	if lines.Load() != 2 {
+		t.Fatalf("Expected 2 status lines, got %d", lines.Load())
+	}
+}
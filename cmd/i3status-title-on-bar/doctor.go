@@ -0,0 +1,425 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/process"
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+const doctorHelpText = `Usage: i3status-title-on-bar doctor [OPTIONS...]
+
+  Check everything the title needs to show up on the bar, the X11 display and
+  window manager, i3status and the pipes in between, and print what passed,
+  what failed and how to fix it. Pipe i3status into it to check the pipe too.
+
+Options:
+  --display [:N]           X11 display to check (Defaults to $DISPLAY)
+  --help                   Print this help text and exit
+
+Examples:
+  i3status-title-on-bar doctor
+  i3status | i3status-title-on-bar doctor`
+
+// The outcomes of a check.
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+// A check done by the doctor, with how to fix it when it didn't pass.
+type check struct {
+	name   string
+	status string
+	detail string
+	fix    string
+}
+
+// What the doctor needs from the X11 display.
+type x11Diagnostics interface {
+	WindowManager() (string, error)
+	SupportedAtoms() ([]string, error)
+	MissingAtoms() []string
+	ActiveWindow() (window.Info, error)
+	Close()
+}
+
+// Closes the connection to the display of the wrapped window.X11.
+type x11Connection struct {
+	*window.X11
+}
+
+func (x11 x11Connection) Close() {
+	x11.XConnection.Close()
+}
+
+// Everything the doctor looks at, swappable for testing.
+type doctorEnv struct {
+	getenv      func(name string) string
+	lookPath    func(file string) (string, error)
+	output      func(name string, args ...string) ([]byte, error)
+	openX11     func(display string) (x11Diagnostics, error)
+	findPids    func(name string) []int
+	refreshUSR1 func(path string) error
+	stdinMode   os.FileMode
+	stdoutMode  os.FileMode
+	stdioFailed error
+}
+
+// Look at the real environment of this process.
+func newDoctorEnv() doctorEnv {
+	env := doctorEnv{
+		getenv:   os.Getenv,
+		lookPath: exec.LookPath,
+		output: func(name string, args ...string) ([]byte, error) {
+			return exec.Command(name, args...).Output()
+		},
+		openX11: func(display string) (x11Diagnostics, error) {
+			x11, err := window.NewX11ForDisplay(display)
+			if err != nil {
+				return nil, err
+			}
+			return x11Connection{x11}, nil
+		},
+		findPids:    process.FindPidsByProcessName,
+		refreshUSR1: statusRefreshesOnUSR1,
+	}
+	stdin, err := os.Stdin.Stat()
+	if err != nil {
+		env.stdioFailed = err
+		return env
+	}
+	stdout, err := os.Stdout.Stat()
+	if err != nil {
+		env.stdioFailed = err
+		return env
+	}
+	env.stdinMode, env.stdoutMode = stdin.Mode(), stdout.Mode()
+	return env
+}
+
+// Run the doctor subcommand with the given arguments, returning the exit code,
+// which is DoctorFailedErrorCode when any check failed.
+func runDoctor(stdout io.Writer, stderr io.Writer, args []string) int {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		display   = fs.String("display", "", "X11 display to check")
+		printHelp = fs.Bool("help", false, "Print additional help text and exit")
+	)
+	// disable default output
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(args[1:]); err != nil {
+		fmt.Fprintln(stdout, err.Error()+"\n")
+		fmt.Fprintln(stdout, doctorHelpText)
+		return BadConfigErrorCode
+	}
+	if *printHelp {
+		fmt.Fprintln(stdout, doctorHelpText)
+		return PrintErrorCode
+	}
+
+	checks := diagnose(newDoctorEnv(), *display)
+	printReport(stdout, checks)
+	for _, check := range checks {
+		if check.status == checkFail {
+			return DoctorFailedErrorCode
+		}
+	}
+	return 0
+}
+
+// Run every check in the given environment.
+func diagnose(env doctorEnv, display string) []check {
+	checks := diagnoseX11(env, display)
+	checks = append(checks, diagnoseStatus(env)...)
+	return append(checks, diagnosePipes(env)...)
+}
+
+// Check the X11 display, its window manager and the active window.
+func diagnoseX11(env doctorEnv, display string) []check {
+	skipped := func(reason string) []check {
+		var checks []check
+		for _, name := range []string{"X connection", "Window manager", "EWMH atoms", "Active window"} {
+			checks = append(checks, check{name: name, status: checkSkip, detail: reason})
+		}
+		return checks
+	}
+
+	if display == "" {
+		display = env.getenv("DISPLAY")
+	}
+	if display == "" {
+		displayCheck := check{"DISPLAY", checkFail, "not set",
+			"Run it from inside your X session, where $DISPLAY is set, or pass --display :0"}
+		if env.getenv("WAYLAND_DISPLAY") != "" {
+			displayCheck.status = checkWarn
+			displayCheck.detail = "not set, but $WAYLAND_DISPLAY is"
+			displayCheck.fix = "Under sway or Hyprland the sway and hyprland backends don't need X11, pick one with --backend"
+		}
+		return append([]check{displayCheck}, skipped("no display")...)
+	}
+	checks := []check{{name: "DISPLAY", status: checkPass, detail: display}}
+
+	x11, err := env.openX11(display)
+	if err != nil {
+		checks = append(checks, check{"X connection", checkFail, err.Error(),
+			"Check that the X server is running and that $XAUTHORITY lets you connect, like with xdpyinfo, otherwise the bar exits with code 9"})
+		return append(checks, skipped("no X connection")[1:]...)
+	}
+	defer x11.Close()
+	checks = append(checks, check{name: "X connection", status: checkPass, detail: "connected to " + display})
+
+	windowManager := check{name: "Window manager", status: checkPass, detail: "unnamed"}
+	if name, err := x11.WindowManager(); err != nil {
+		windowManager.status, windowManager.detail = checkFail, err.Error()
+		windowManager.fix = "Use an EWMH compliant window manager, like i3, or --backend i3ipc with i3"
+	} else if name != "" {
+		windowManager.detail = name
+	}
+	checks = append(checks, windowManager)
+
+	missing := x11.MissingAtoms()
+	atoms := check{name: "EWMH atoms", status: checkPass, detail: "every atom used exists"}
+	supported, err := x11.SupportedAtoms()
+	if err != nil {
+		atoms.status, atoms.detail = checkFail, err.Error()
+	} else if slices.Contains(missing, "_NET_ACTIVE_WINDOW") {
+		atoms.status, atoms.detail = checkFail, "missing "+strings.Join(missing, ", ")
+		atoms.fix = "Without _NET_ACTIVE_WINDOW there is no title to show, use an EWMH compliant window manager"
+	} else if len(missing) > 0 {
+		atoms.status, atoms.detail = checkWarn, "missing "+strings.Join(missing, ", ")
+		atoms.fix = "Window managers and applications set these once they're used, until then what needs them stays empty, like {workspace} without _NET_CURRENT_DESKTOP"
+	} else if !slices.Contains(supported, "_NET_ACTIVE_WINDOW") {
+		atoms.status, atoms.detail = checkWarn, "_NET_SUPPORTED doesn't list _NET_ACTIVE_WINDOW"
+		atoms.fix = "The window manager may not keep the active window up to date, try --backend i3ipc with i3"
+	}
+	checks = append(checks, atoms)
+
+	active := check{name: "Active window", status: checkPass}
+	if info, err := x11.ActiveWindow(); errors.Is(err, window.ErrNoActiveWindow) {
+		active.status, active.detail = checkWarn, "_NET_ACTIVE_WINDOW is empty"
+		active.fix = "Focus a window and run doctor again, with none focused the bar shows --no-window-text"
+	} else if err != nil {
+		active.status, active.detail = checkFail, err.Error()
+	} else {
+		// the title is left out, it's what privacy mode and masks hide
+		active.detail = fmt.Sprintf("0x%x %s", info.ID, info.Class)
+	}
+	return append(checks, active)
+}
+
+// Check pgrep, and that i3status is installed, refreshes on USR1 and is
+// running.
+func diagnoseStatus(env doctorEnv) []check {
+	var checks []check
+	pgrep := check{name: "pgrep", status: checkPass}
+	pgrepPath, err := env.lookPath("pgrep")
+	if err != nil {
+		pgrep.status, pgrep.detail = checkFail, "not found in $PATH"
+		pgrep.fix = "Install procps, which has pgrep, it's used to find i3status to signal"
+	} else {
+		pgrep.detail = pgrepPath
+	}
+	checks = append(checks, pgrep)
+
+	installed := check{name: "i3status", status: checkPass}
+	if path, err := env.lookPath("i3status"); err != nil {
+		installed.status, installed.detail = checkFail, "not found in $PATH"
+		installed.fix = "Install i3status, the status line this adds the title to"
+	} else {
+		out, err := env.output(path, "--version")
+		if version := parseStatusVersion(string(out)); err != nil || version == "" {
+			installed.status, installed.detail = checkWarn, path+", unknown version"
+			installed.fix = "Check that i3status --version works"
+		} else if err := env.refreshUSR1(path); err != nil {
+			installed.status, installed.detail = checkWarn, fmt.Sprintf("%s %s, didn't refresh on USR1: %s", path, version, err)
+			installed.fix = "Upgrade i3status, otherwise a new title only shows up at its next interval"
+		} else {
+			installed.detail = fmt.Sprintf("%s %s, refreshes on USR1", path, version)
+		}
+	}
+	checks = append(checks, installed)
+
+	running := check{name: "i3status running", status: checkPass}
+	if pgrep.status != checkPass {
+		running.status, running.detail = checkSkip, "no pgrep"
+	} else if pids := env.findPids("i3status"); len(pids) == 0 {
+		running.status, running.detail = checkFail, "no i3status process found"
+		running.fix = "Start i3status first, like status_command i3status | i3status-title-on-bar, otherwise the bar exits with code 8"
+	} else {
+		var detail []string
+		for _, pid := range pids {
+			detail = append(detail, strconv.Itoa(pid))
+		}
+		running.detail = "PID " + strings.Join(detail, ", ")
+	}
+	return append(checks, running)
+}
+
+// Check what stdin and stdout are connected to.
+func diagnosePipes(env doctorEnv) []check {
+	if env.stdioFailed != nil {
+		return []check{{name: "stdin/stdout", status: checkFail, detail: env.stdioFailed.Error()}}
+	}
+	stdin := check{name: "stdin", status: checkPass, detail: describeMode(env.stdinMode)}
+	if env.stdinMode&os.ModeNamedPipe == 0 && !env.stdinMode.IsRegular() {
+		stdin.status = checkWarn
+		stdin.fix = "The bar reads i3status through a pipe, like i3status | i3status-title-on-bar, pipe it into doctor to check it"
+	}
+	stdout := check{name: "stdout", status: checkPass, detail: describeMode(env.stdoutMode)}
+	if env.stdoutMode&os.ModeCharDevice != 0 {
+		stdout.detail += ", i3bar reads it through a pipe"
+	}
+	return []check{stdin, stdout}
+}
+
+// Describe what a file with the given mode is, as far as pipes go.
+func describeMode(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "a pipe"
+	case mode&os.ModeCharDevice != 0:
+		return "a terminal"
+	case mode.IsRegular():
+		return "a file"
+	case mode&os.ModeSocket != 0:
+		return "a socket"
+	}
+	return "closed"
+}
+
+// A configuration that makes i3status print a line at start, and otherwise
+// only once an hour.
+const usr1StatusConfig = `general {
+	output_format = "none"
+	interval = 3600
+}
+order += "tztime local"
+tztime local {
+	format = "%H:%M:%S"
+}
+`
+
+// How long i3status gets to print each line when checking USR1.
+const usr1Timeout = 2 * time.Second
+
+// Run the i3status at the given path with a configuration that only refreshes
+// once an hour, and check that it prints a second line right after a USR1
+// signal, which is what the bar sends it whenever the title changes.
+func statusRefreshesOnUSR1(path string) error {
+	config, err := os.CreateTemp("", "i3status-title-on-bar-doctor-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(config.Name())
+	_, err = config.WriteString(usr1StatusConfig)
+	if closeErr := config.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	command := exec.Command(path, "-c", config.Name())
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := command.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		command.Process.Kill()
+		command.Wait()
+	}()
+
+	lines := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case lines <- struct{}{}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	waitForLine := func() error {
+		select {
+		case _, ok := <-lines:
+			if !ok {
+				return errors.New("i3status exited")
+			}
+			return nil
+		case <-time.After(usr1Timeout):
+			return errors.New("no status line")
+		}
+	}
+	if err := waitForLine(); err != nil {
+		return err
+	}
+	if err := command.Process.Signal(syscall.SIGUSR1); err != nil {
+		return err
+	}
+	return waitForLine()
+}
+
+// Get the version from the output of i3status --version, like
+// "i3status 2.14 © 2008 Michael Stapelberg and contributors", or an empty
+// string when it doesn't look like one.
+func parseStatusVersion(output string) string {
+	fields := strings.Fields(output)
+	if len(fields) < 2 || fields[0] != "i3status" {
+		return ""
+	}
+	version := fields[1]
+	if _, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0]); err != nil {
+		return ""
+	}
+	return version
+}
+
+// Print a line for each check, followed by its fix, and a summary.
+func printReport(stdout io.Writer, checks []check) {
+	counts := map[string]int{}
+	for _, check := range checks {
+		fmt.Fprintf(stdout, "%s  %-17s %s\n", check.status, check.name, check.detail)
+		if check.fix != "" {
+			fmt.Fprintf(stdout, "      fix: %s\n", check.fix)
+		}
+		counts[check.status]++
+	}
+	fmt.Fprintf(stdout, "\n%d passed, %d warned, %d failed, %d skipped\n",
+		counts[checkPass], counts[checkWarn], counts[checkFail], counts[checkSkip])
+}
@@ -0,0 +1,333 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// A display with a window manager, answering with what's set.
+type fakeX11 struct {
+	windowManager string
+	supported     []string
+	missing       []string
+	active        window.Info
+	activeErr     error
+	closed        bool
+}
+
+func (x11 *fakeX11) WindowManager() (string, error) {
+	if x11.windowManager == "" {
+		return "", window.ErrNoWindowManager
+	}
+	return x11.windowManager, nil
+}
+
+func (x11 *fakeX11) SupportedAtoms() ([]string, error) {
+	return x11.supported, nil
+}
+
+func (x11 *fakeX11) MissingAtoms() []string {
+	return x11.missing
+}
+
+func (x11 *fakeX11) ActiveWindow() (window.Info, error) {
+	return x11.active, x11.activeErr
+}
+
+func (x11 *fakeX11) Close() {
+	x11.closed = true
+}
+
+// An environment where everything is set up right.
+func healthyDoctorEnv(x11 *fakeX11) doctorEnv {
+	return doctorEnv{
+		getenv: func(name string) string {
+			return map[string]string{"DISPLAY": ":0"}[name]
+		},
+		lookPath: func(file string) (string, error) {
+			return "/usr/bin/" + file, nil
+		},
+		output: func(name string, args ...string) ([]byte, error) {
+			return []byte("i3status 2.14 © 2008 Michael Stapelberg and contributors\n"), nil
+		},
+		openX11: func(display string) (x11Diagnostics, error) {
+			return x11, nil
+		},
+		findPids: func(name string) []int {
+			return []int{1234}
+		},
+		refreshUSR1: func(path string) error {
+			return nil
+		},
+		stdinMode:  os.ModeNamedPipe,
+		stdoutMode: os.ModeNamedPipe,
+	}
+}
+
+func newHealthyX11() *fakeX11 {
+	return &fakeX11{
+		windowManager: "i3",
+		supported:     []string{"_NET_SUPPORTED", "_NET_ACTIVE_WINDOW"},
+		active:        window.Info{ID: 0x1a, Class: "Alacritty", Title: "vim"},
+	}
+}
+
+// Index the checks by name, failing when two have the same name.
+func checksByName(t *testing.T, checks []check) map[string]check {
+	byName := map[string]check{}
+	for _, check := range checks {
+		if _, found := byName[check.name]; found {
+			t.Fatalf("Expected a single %s check", check.name)
+		}
+		byName[check.name] = check
+	}
+	return byName
+}
+
+func TestDiagnoseHealthy(t *testing.T) {
+	x11 := newHealthyX11()
+	checks := checksByName(t, diagnose(healthyDoctorEnv(x11), ""))
+	expected := map[string]string{
+		"DISPLAY":          ":0",
+		"X connection":     "connected to :0",
+		"Window manager":   "i3",
+		"EWMH atoms":       "every atom used exists",
+		"Active window":    "0x1a Alacritty",
+		"pgrep":            "/usr/bin/pgrep",
+		"i3status":         "/usr/bin/i3status 2.14, refreshes on USR1",
+		"i3status running": "PID 1234",
+		"stdin":            "a pipe",
+		"stdout":           "a pipe",
+	}
+	if len(checks) != len(expected) {
+		t.Fatalf("Expected %d checks, got %v", len(expected), checks)
+	}
+	for name, detail := range expected {
+		check := checks[name]
+		if check.status != checkPass || check.detail != detail || check.fix != "" {
+			t.Errorf("Expected %s to pass with %q, got %+v", name, detail, check)
+		}
+	}
+	if !x11.closed {
+		t.Fatal("Expected the X connection to be closed")
+	}
+}
+
+func TestDiagnoseProblems(t *testing.T) {
+	x11 := newHealthyX11()
+	x11.windowManager = ""
+	x11.missing = []string{"_NET_CURRENT_DESKTOP"}
+	x11.activeErr = window.ErrNoActiveWindow
+	env := healthyDoctorEnv(x11)
+	env.output = func(name string, args ...string) ([]byte, error) {
+		return nil, errors.New("exit status 1")
+	}
+	env.findPids = func(name string) []int {
+		return nil
+	}
+	env.stdinMode = os.ModeDevice | os.ModeCharDevice
+	env.stdoutMode = os.ModeDevice | os.ModeCharDevice
+
+	checks := checksByName(t, diagnose(env, ":1"))
+	expected := map[string]string{
+		"DISPLAY":          checkPass,
+		"Window manager":   checkFail,
+		"EWMH atoms":       checkWarn,
+		"Active window":    checkWarn,
+		"i3status":         checkWarn,
+		"i3status running": checkFail,
+		"stdin":            checkWarn,
+		"stdout":           checkPass,
+	}
+	for name, status := range expected {
+		check := checks[name]
+		if check.status != status {
+			t.Errorf("Expected %s to be %s, got %+v", name, status, check)
+		}
+		if status != checkPass && check.fix == "" {
+			t.Errorf("Expected a fix for %s", name)
+		}
+	}
+	if checks["DISPLAY"].detail != ":1" {
+		t.Fatalf("Expected the display of the option, got %q", checks["DISPLAY"].detail)
+	}
+	if checks["EWMH atoms"].detail != "missing _NET_CURRENT_DESKTOP" {
+		t.Fatalf("Expected the missing atom, got %q", checks["EWMH atoms"].detail)
+	}
+}
+
+func TestDiagnoseWithoutActiveWindowAtom(t *testing.T) {
+	x11 := newHealthyX11()
+	x11.missing = []string{"_NET_ACTIVE_WINDOW", "_NET_WM_PID"}
+	checks := checksByName(t, diagnose(healthyDoctorEnv(x11), ""))
+	atoms := checks["EWMH atoms"]
+	if atoms.status != checkFail || atoms.detail != "missing _NET_ACTIVE_WINDOW, _NET_WM_PID" {
+		t.Fatalf("Expected missing _NET_ACTIVE_WINDOW to fail, got %+v", atoms)
+	}
+
+	x11 = newHealthyX11()
+	x11.supported = []string{"_NET_SUPPORTED"}
+	checks = checksByName(t, diagnose(healthyDoctorEnv(x11), ""))
+	if checks["EWMH atoms"].status != checkWarn {
+		t.Fatalf("Expected unsupported _NET_ACTIVE_WINDOW to warn, got %+v", checks["EWMH atoms"])
+	}
+}
+
+func TestDiagnoseWithoutDisplay(t *testing.T) {
+	env := healthyDoctorEnv(nil)
+	env.getenv = func(name string) string {
+		return ""
+	}
+	env.openX11 = func(display string) (x11Diagnostics, error) {
+		t.Fatal("Expected no X connection without a display")
+		return nil, nil
+	}
+	checks := checksByName(t, diagnose(env, ""))
+	if checks["DISPLAY"].status != checkFail {
+		t.Fatalf("Expected a missing display to fail, got %+v", checks["DISPLAY"])
+	}
+	for _, name := range []string{"X connection", "Window manager", "EWMH atoms", "Active window"} {
+		if checks[name].status != checkSkip {
+			t.Errorf("Expected %s to be skipped, got %+v", name, checks[name])
+		}
+	}
+
+	env.getenv = func(name string) string {
+		return map[string]string{"WAYLAND_DISPLAY": "wayland-1"}[name]
+	}
+	checks = checksByName(t, diagnose(env, ""))
+	if checks["DISPLAY"].status != checkWarn || !strings.Contains(checks["DISPLAY"].fix, "--backend") {
+		t.Fatalf("Expected a hint for the Wayland backends, got %+v", checks["DISPLAY"])
+	}
+}
+
+func TestDiagnoseBadDisplay(t *testing.T) {
+	env := healthyDoctorEnv(nil)
+	env.openX11 = func(display string) (x11Diagnostics, error) {
+		return nil, errors.New("can't open display")
+	}
+	checks := checksByName(t, diagnose(env, ""))
+	if checks["X connection"].status != checkFail || checks["X connection"].detail != "can't open display" {
+		t.Fatalf("Expected the connection to fail, got %+v", checks["X connection"])
+	}
+	if checks["Window manager"].status != checkSkip {
+		t.Fatalf("Expected the window manager to be skipped, got %+v", checks["Window manager"])
+	}
+}
+
+func TestDiagnoseWithoutTools(t *testing.T) {
+	env := healthyDoctorEnv(newHealthyX11())
+	env.lookPath = func(file string) (string, error) {
+		return "", errors.New("not found")
+	}
+	checks := checksByName(t, diagnose(env, ""))
+	for name, status := range map[string]string{"pgrep": checkFail, "i3status": checkFail, "i3status running": checkSkip} {
+		if checks[name].status != status {
+			t.Errorf("Expected %s to be %s, got %+v", name, status, checks[name])
+		}
+	}
+}
+
+func TestDiagnoseStatusWithoutUSR1(t *testing.T) {
+	env := healthyDoctorEnv(newHealthyX11())
+	env.refreshUSR1 = func(path string) error {
+		return errors.New("no status line")
+	}
+	checks := checksByName(t, diagnose(env, ""))
+	installed := checks["i3status"]
+	if installed.status != checkWarn || installed.detail != "/usr/bin/i3status 2.14, didn't refresh on USR1: no status line" || installed.fix == "" {
+		t.Fatalf("Expected a warning for i3status not refreshing on USR1, got %+v", installed)
+	}
+}
+
+func TestStatusRefreshesOnUSR1(t *testing.T) {
+	// a stand-in printing a line at start and on every USR1
+	script := writeConfig(t, filepath.Join(t.TempDir(), "i3status"),
+		"#!/bin/sh\ntrap 'echo refreshed' USR1\necho started\nwhile true; do sleep 0.01; done\n")
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := statusRefreshesOnUSR1(script); err != nil {
+		t.Fatal(err)
+	}
+
+	deaf := writeConfig(t, filepath.Join(t.TempDir(), "i3status"),
+		"#!/bin/sh\ntrap '' USR1\necho started\nwhile true; do sleep 0.01; done\n")
+	if err := os.Chmod(deaf, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := statusRefreshesOnUSR1(deaf); err == nil {
+		t.Fatal("Expected an i3status ignoring USR1 to fail")
+	}
+}
+
+func TestParseStatusVersion(t *testing.T) {
+	tests := map[string]string{
+		"i3status 2.14 © 2008 Michael Stapelberg and contributors\n": "2.14",
+		"i3status 2.13-non-git\n":                                    "2.13-non-git",
+		"i3status v1\n":                                              "",
+		"something else 2.14\n":                                      "",
+		"":                                                           "",
+	}
+	for output, expected := range tests {
+		if version := parseStatusVersion(output); version != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, output, version)
+		}
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	var stdout bytes.Buffer
+	printReport(&stdout, []check{
+		{name: "DISPLAY", status: checkPass, detail: ":0"},
+		{name: "i3status running", status: checkFail, detail: "no i3status process found", fix: "Start i3status first"},
+		{name: "stdin", status: checkWarn, detail: "a terminal", fix: "Pipe i3status into it"},
+	})
+	expected := strings.Join([]string{
+		"PASS  DISPLAY           :0",
+		"FAIL  i3status running  no i3status process found",
+		"      fix: Start i3status first",
+		"WARN  stdin             a terminal",
+		"      fix: Pipe i3status into it",
+		"",
+		"1 passed, 1 warned, 1 failed, 0 skipped",
+		"",
+	}, "\n")
+	if stdout.String() != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+}
+
+func TestDoctorHelp(t *testing.T) {
+	var stdout bytes.Buffer
+	code := runDoctor(&stdout, &stdout, []string{"doctor", "--help"})
+	if code != PrintErrorCode || !strings.Contains(stdout.String(), doctorHelpText) {
+		t.Fatalf("Expected the help text, got %d: %s", code, stdout.String())
+	}
+
+	stdout.Reset()
+	code = runDoctor(&stdout, &stdout, []string{"doctor", "--bogus"})
+	if code != BadConfigErrorCode {
+		t.Fatalf("Expected a bad config, got %d: %s", code, stdout.String())
+	}
+}
@@ -15,12 +15,22 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/rholder/i3status-title-on-bar/pkg/control"
 	"github.com/rholder/i3status-title-on-bar/pkg/i3"
 	"github.com/rholder/i3status-title-on-bar/pkg/process"
 	"github.com/rholder/i3status-title-on-bar/pkg/sampler"
@@ -30,43 +40,140 @@ import (
 // Version override via: go build "-ldflags main.Version=x.x.x", defaults to 0.0.0-dev if unset
 var Version = "0.0.0-dev"
 
-// No title will change with a frequency higher than this value. See https://www.nngroup.com/articles/response-times-3-important-limits/
+// By default, no title will change with a frequency higher than this value. See https://www.nngroup.com/articles/response-times-3-important-limits/
 const titleChangeSampleMs = 100
 const titleChangeEventBufferSize = 1000
 const defaultColor = "#00FF00"
+const defaultBackend = window.AutoBackend
 const helpText = `Usage: i3status-title-on-bar [OPTIONS...]
+       i3status-title-on-bar COMMAND [OPTIONS...]
 
   Use i3status-title-on-bar to prepend the currently active X11 window title
   to the beginning (left) of the i3status output JSON as a new node. From there,
   i3status should be able to pick it up and display it on the bar.
 
+Commands:
+  test-rules               Show how the configured rules transform a sample title
+  ctl                      Query or change the running instance through its control socket
+  watch                    Print a JSON line for each focus or title change, without i3status
+  doctor                   Check the display, window manager and i3status, suggesting fixes
+
 Options:
   --color [i3_color_code]  Set the text color of the JSON node (Defaults to #00FF00)
+  --format [template]      Template for the title text, fields are {title}, {class},
+                           {instance}, {role}, {workspace}, {icon}, and for terminals
+                           {command} and {cwd} of the foreground process (Defaults to
+                           {title})
+  --workspace-block        Add a JSON node with the current workspace name before the title
+  --urgent-block           Add an urgent JSON node listing windows demanding attention after
+                           the title, left out when there are none
+  --badges [b=glyph,...]   Glyphs for the window state badges fullscreen, maximized, sticky,
+                           hidden, above and floating, each also a --format field, and
+                           {badges} for all that apply
+  --badge-blocks [b,...]   Add a JSON node after the title for each of these badges that
+                           applies
   --append-end             Append window title JSON node to the end instead of the beginning
   --fixed-width [integer]  Truncate and pad to a fixed width, useful with append-end
+  --backend [name]         Window backend to use: auto, sway, hyprland, x11, i3ipc or fake
+                           (Defaults to auto, picking the first usable in that order)
+  --fake-script [path]     Script of window events replayed by the fake backend
+  --output [name]          Only show windows on this RandR output, like DP-1 (x11 only)
+  --display [:N,...]       X11 displays to watch, each with its own title (implies x11)
+  --ignore-type [type,...] Ignore windows of these types, like dialog,dock,notification
+  --ignore-class [c,...]   Ignore windows of these WM_CLASS classes or instances
+  --ignore-role [role,...] Ignore windows with these WM_WINDOW_ROLE roles
+  --ignore-title [regex]   Ignore windows with titles matching this regular expression
+  --focus-dwell [ms]       Only show a newly focused window once it stayed focused this long
+  --terminal-classes [c]   Window classes treated as terminals for {command} and {cwd}
+                           (Defaults to common terminal emulators like Alacritty,kitty,foot)
+  --terminal-timeout [ms]  Give up looking for a terminal's foreground process after this long
+                           (Defaults to 50)
+  --no-window-text [text]  Show this instead of a title when no window is active
+  --no-window-color [code] Text color used with --no-window-text (Defaults to --color)
+  --omit-no-window         Leave out the JSON node entirely when no window is active
+  --error-text [text]      Show this instead of a title when the window backend fails
+  --error-color [code]     Text color used with --error-text (Defaults to --color)
+  --sample-interval [ms]   Minimum time between updates of i3status (Defaults to 100)
+  --config [path]          JSON configuration file with any of these options and settings
+                           like per-class icons, title rewrite, color and mask rules
+                           (Defaults to $XDG_CONFIG_HOME/i3status-title-on-bar/config.json,
+                           or the same under ~/.config or $XDG_CONFIG_DIRS if it exists)
+  --watch-config           Reload the configuration file whenever it changes (Linux only)
+  --control-socket [path]  Unix socket answering the ctl command, left out when empty
+                           (Defaults to $XDG_RUNTIME_DIR/i3status-title-on-bar.sock)
   --help                   Print this help text and exit
   --version                Print the version and exit
 
+Configuration:
+  Options are taken from the configuration file first, then from environment
+  variables named after them, like I3STATUS_TITLE_ON_BAR_FIXED_WIDTH for
+  --fixed-width, and last from the command line, each overriding the one before.
+  In the file they're named with underscores, like "fixed_width": 64.
+
+Signals:
+  HUP                      Reload the configuration, keeping the running one when the new
+                           one has mistakes
+  USR2                     Toggle privacy mode, hiding every title until toggled again
+
 Examples:
   i3status | i3status-title-on-bar --color '#00EE00'
   i3status | i3status-title-on-bar --append-end --fixed-width 64
   i3status-title-on-bar < i3status-output-example.json
+  i3status | i3status-title-on-bar --backend fake --fake-script demo.fake
+  i3status | i3status-title-on-bar --output DP-1
+  i3status | i3status-title-on-bar --display :0,:1
+  i3status | i3status-title-on-bar --ignore-class rofi,dmenu --ignore-type notification
+  i3status | i3status-title-on-bar --focus-dwell 300
+  i3status | i3status-title-on-bar --no-window-text 'desktop' --error-text '?'
+  i3status | i3status-title-on-bar --format '[{workspace}] {title}'
+  i3status | i3status-title-on-bar --format '{command} — {cwd}'
+  i3status | i3status-title-on-bar --format '{badges} {title}' --badges fullscreen=F
+  i3status | i3status-title-on-bar --config ~/.config/i3status-title-on-bar/config.json
+  pkill -USR2 i3status-title
+  pkill -HUP i3status-title
+  i3status-title-on-bar ctl override 'presenting' --for 600
+  i3status | i3status-title-on-bar doctor
 
 Report bugs and find the latest updates at https://github.com/rholder/i3status-title-on-bar.`
 
+// These run instead of the usual status line processing when named as the
+// first argument, getting the arguments that follow the name.
+var subcommands = map[string]func(stdout io.Writer, stderr io.Writer, args []string) int{
+	"test-rules": runTestRules,
+	"ctl":        runCtl,
+	"watch":      runWatch,
+	"doctor":     runDoctor,
+}
+
 // Non-zero error codes signal different bad exit conditions. Zero is ok.
 const (
 	PrintErrorCode                int = 1
 	BadConfigErrorCode            int = 2
 	MissingStatusProcessErrorCode int = 8
 	BadDisplayErrorCode           int = 9
+	ControlErrorCode              int = 10
+	DoctorFailedErrorCode         int = 11
+	WatchFailedErrorCode          int = 12
 )
 
 // Config stores a bit of configuration for the CLI.
 type Config struct {
-	color        string
-	appendEnd    bool
-	fixedWidth   int
+	color         string
+	appendEnd     bool
+	fixedWidth    int
+	backend       string
+	fakeScript    string
+	output        string
+	displays      []string
+	ignoreRules   window.IgnoreRules
+	focusDwellMs  int
+	sampleMs      int
+	configPath    string
+	watchConfig   bool
+	controlSocket string
+	// titleOptions holds the rest of the title node options besides color,
+	// position and width
+	titleOptions i3.Options
 	printHelp    bool
 	printVersion bool
 }
@@ -74,17 +181,156 @@ type Config struct {
 func newConfig(name string, args []string) (*Config, error) {
 	fs := flag.NewFlagSet(name, flag.ContinueOnError)
 	var (
-		color        = fs.String("color", defaultColor, "Set the text color of the JSON node")
-		appendEnd    = fs.Bool("append-end", false, "Append window title JSON node to the end")
-		fixedWidth   = fs.Int("fixed-width", 0, "Trucate and pad to a fixed width")
-		printHelp    = fs.Bool("help", false, "Print additional help text and exit")
-		printVersion = fs.Bool("version", false, "Print the version and exit")
+		color         = fs.String("color", defaultColor, "Set the text color of the JSON node")
+		appendEnd     = fs.Bool("append-end", false, "Append window title JSON node to the end")
+		fixedWidth    = fs.Int("fixed-width", 0, "Trucate and pad to a fixed width")
+		backend       = fs.String("backend", defaultBackend, "Window backend to use")
+		fakeScript    = fs.String("fake-script", "", "Script of window events for the fake backend")
+		output        = fs.String("output", "", "Only show windows on this RandR output")
+		display       = fs.String("display", "", "X11 displays to watch")
+		ignoreType    = fs.String("ignore-type", "", "Ignore windows of these types")
+		ignoreClass   = fs.String("ignore-class", "", "Ignore windows of these classes")
+		ignoreRole    = fs.String("ignore-role", "", "Ignore windows with these roles")
+		ignoreTitle   = fs.String("ignore-title", "", "Ignore windows with titles matching this regex")
+		focusDwellMs  = fs.Int("focus-dwell", 0, "Minimum time a window stays focused before it's shown")
+		termClasses   = fs.String("terminal-classes", "", "Window classes treated as terminals")
+		termTimeoutMs = fs.Int("terminal-timeout", int(i3.DefaultTerminalTimeout/time.Millisecond), "Time limit for finding a terminal's foreground process")
+		format        = fs.String("format", i3.DefaultFormat, "Template for the title text")
+		workspaceNode = fs.Bool("workspace-block", false, "Add a JSON node with the current workspace")
+		urgentNode    = fs.Bool("urgent-block", false, "Add a JSON node listing urgent windows")
+		badges        = fs.String("badges", "", "Glyphs shown for window states")
+		badgeBlocks   = fs.String("badge-blocks", "", "Window states shown in their own JSON node")
+		noWindowText  = fs.String("no-window-text", "", "Text shown when no window is active")
+		noWindowColor = fs.String("no-window-color", "", "Text color used when no window is active")
+		omitNoWindow  = fs.Bool("omit-no-window", false, "Leave out the JSON node when no window is active")
+		errorText     = fs.String("error-text", "", "Text shown when the window backend fails")
+		errorColor    = fs.String("error-color", "", "Text color used when the window backend fails")
+		sampleMs      = fs.Int("sample-interval", titleChangeSampleMs, "Minimum time between updates of i3status")
+		_             = fs.String("config", "", "Path to a JSON configuration file")
+		watchConfig   = fs.Bool("watch-config", false, "Reload the configuration file whenever it changes")
+		controlSocket = fs.String("control-socket", control.DefaultPath(), "Unix socket answering the ctl subcommand")
+		printHelp     = fs.Bool("help", false, "Print additional help text and exit")
+		printVersion  = fs.Bool("version", false, "Print the version and exit")
 	)
 	// disable default output
 	fs.SetOutput(ioutil.Discard)
-	err := fs.Parse(args)
+	file, err := layerSettings(fs, args)
+
+	config := &Config{
+		color:         *color,
+		appendEnd:     *appendEnd,
+		fixedWidth:    *fixedWidth,
+		backend:       *backend,
+		fakeScript:    *fakeScript,
+		output:        *output,
+		displays:      splitList(*display),
+		focusDwellMs:  *focusDwellMs,
+		sampleMs:      *sampleMs,
+		watchConfig:   *watchConfig,
+		controlSocket: *controlSocket,
+		titleOptions: i3.Options{
+			Format:          *format,
+			WorkspaceBlock:  *workspaceNode,
+			UrgentBlock:     *urgentNode,
+			NoWindowText:    *noWindowText,
+			NoWindowColor:   *noWindowColor,
+			OmitNoWindow:    *omitNoWindow,
+			ErrorText:       *errorText,
+			ErrorColor:      *errorColor,
+			TerminalClasses: splitList(*termClasses),
+			TerminalTimeout: time.Millisecond * time.Duration(*termTimeoutMs),
+		},
+		printHelp:    *printHelp,
+		printVersion: *printVersion,
+	}
+
+	config.ignoreRules = window.IgnoreRules{
+		Types:   splitList(*ignoreType),
+		Classes: splitList(*ignoreClass),
+		Roles:   splitList(*ignoreRole),
+	}
+	if *ignoreTitle != "" && err == nil {
+		var titleRegexp *regexp.Regexp
+		titleRegexp, err = regexp.Compile(*ignoreTitle)
+		if err == nil {
+			config.ignoreRules.Titles = []*regexp.Regexp{titleRegexp}
+		}
+	}
+
+	if err == nil {
+		config.titleOptions.BadgeGlyphs, err = parseBadgeGlyphs(*badges)
+	}
+	if err == nil {
+		config.titleOptions.BadgeBlocks = splitList(*badgeBlocks)
+		for _, badge := range config.titleOptions.BadgeBlocks {
+			if !slices.Contains(i3.Badges, badge) {
+				err = fmt.Errorf("unknown badge %q, expected one of %s", badge, strings.Join(i3.Badges, ", "))
+			}
+		}
+	}
+
+	// only x11 can connect to another display, auto picks it for them
+	if err == nil && len(config.displays) > 0 && config.backend != window.AutoBackend && config.backend != "x11" {
+		err = fmt.Errorf("--display only applies to the x11 backend, not %s", config.backend)
+	}
+	if err == nil && config.output != "" && config.backend != window.AutoBackend && config.backend != "x11" {
+		err = fmt.Errorf("--output only applies to the x11 backend, not %s", config.backend)
+	}
+
+	if err == nil && config.sampleMs <= 0 {
+		err = fmt.Errorf("invalid value %d for --sample-interval, expected a positive number of milliseconds", config.sampleMs)
+	}
 
-	return &Config{*color, *appendEnd, *fixedWidth, *printHelp, *printVersion}, err
+	if err == nil && config.watchConfig && file == nil {
+		err = fmt.Errorf("--watch-config needs a configuration file")
+	}
+
+	if file != nil && err == nil {
+		config.configPath = file.Path
+		config.titleOptions.Icons = file.Icons
+		config.titleOptions.DefaultIcon = file.DefaultIcon
+		config.titleOptions.Rewrites = file.Rewrites()
+		config.titleOptions.Colors = file.ColorRules()
+		config.titleOptions.Masks = file.MaskRules()
+		config.titleOptions.MaskText = file.MaskText
+	}
+
+	return config, err
+}
+
+// Return the title options along with the color, position and width.
+func (config *Config) i3Options() i3.Options {
+	options := config.titleOptions
+	options.Color, options.AppendEnd, options.FixedWidth = config.color, config.appendEnd, config.fixedWidth
+	return options
+}
+
+// Parse a comma separated list of badge=glyph pairs, returning nil when it's
+// empty.
+func parseBadgeGlyphs(value string) (map[string]string, error) {
+	var glyphs map[string]string
+	for _, pair := range splitList(value) {
+		badge, glyph, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("expected badge=glyph, got %q", pair)
+		}
+		if !slices.Contains(i3.Badges, badge) {
+			return nil, fmt.Errorf("unknown badge %q, expected one of %s", badge, strings.Join(i3.Badges, ", "))
+		}
+		if glyphs == nil {
+			glyphs = map[string]string{}
+		}
+		glyphs[badge] = glyph
+	}
+	return glyphs, nil
+}
+
+// Split a comma separated flag value, returning nil when it's empty.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
 }
 
 func shouldExit(stdout io.Writer, config *Config, err error) (bool, int) {
@@ -104,14 +350,131 @@ func shouldExit(stdout io.Writer, config *Config, err error) (bool, int) {
 		return true, PrintErrorCode
 	}
 
+	if _, found := window.DefaultRegistry.Lookup(config.backend); !found && config.backend != window.AutoBackend {
+		fmt.Fprintln(stdout, "unknown backend: "+config.backend+"\n")
+		fmt.Fprintln(stdout, helpText)
+		return true, BadConfigErrorCode
+	}
+
 	return false, 0
 }
 
+// Create the title sources for the configured window backend. With displays,
+// one x11 backend is opened for each of them and its title node is named after
+// the display.
+func newTitleSources(stderr io.Writer, config *Config) ([]i3.TitleSource, error) {
+	options := window.Options{FakeScript: config.fakeScript, Output: config.output}
+	if len(config.displays) == 0 {
+		windowAPI, err := newWindowAPI(stderr, config.backend, options)
+		if err != nil {
+			return nil, err
+		}
+		return []i3.TitleSource{{API: wrapWindowAPI(config, windowAPI)}}, nil
+	}
+
+	backend := config.backend
+	if backend == window.AutoBackend {
+		backend = "x11"
+	}
+	var sources []i3.TitleSource
+	for _, display := range config.displays {
+		options.Display = display
+		windowAPI, err := newWindowAPI(stderr, backend, options)
+		if err != nil {
+			return nil, err
+		}
+		// a single display doesn't need telling apart
+		instance := ""
+		if len(config.displays) > 1 {
+			instance = display
+		}
+		sources = append(sources, i3.TitleSource{Instance: instance, API: wrapWindowAPI(config, windowAPI)})
+	}
+	return sources, nil
+}
+
+// Wrap the given window.API with everything configured to filter windows.
+func wrapWindowAPI(config *Config, windowAPI window.API) window.API {
+	if !config.ignoreRules.Empty() {
+		windowAPI = window.NewIgnoring(windowAPI, config.ignoreRules)
+	}
+	if config.focusDwellMs > 0 {
+		windowAPI = window.NewHysteresis(windowAPI, time.Millisecond*time.Duration(config.focusDwellMs))
+	}
+	return windowAPI
+}
+
+// Open the given window backend, reporting to stderr how it was picked when
+// it was detected automatically.
+func newWindowAPI(stderr io.Writer, backend string, options window.Options) (window.API, error) {
+	windowAPI, detection, err := window.DefaultRegistry.Open(backend, options)
+	if backend == window.AutoBackend {
+		for _, rejection := range detection.Rejected {
+			fmt.Fprintf(stderr, "Window backend %s rejected: %s\n", rejection.Name, rejection.Reason)
+		}
+		if err == nil {
+			fmt.Fprintf(stderr, "Window backend %s picked: %s\n", detection.Name, detection.Reason)
+		}
+		if err == nil && options.Output != "" && detection.Name != "x11" {
+			fmt.Fprintf(stderr, "Ignoring --output %s, only the x11 backend picks windows by output\n", options.Output)
+		}
+	}
+	return windowAPI, err
+}
+
+// Send every visible change of the given source's titles to the given channel,
+// counting them, and naming the source's instance in any errors.
+func detectTitleChanges(stderr io.Writer, titleSource i3.TitleSource, stats *stats, titleChangeEvents chan interface{}) {
+	changeFilter := window.ChangeFilter{ActiveWindow: titleSource.API.ActiveWindow}
+	changeFilter.Seed()
+	titleSource.API.DetectWindowTitleChanges(func(event window.Event) {
+		if changeFilter.Changed(event) {
+			stats.windowChanges.Add(1)
+			titleChangeEvents <- event
+		}
+	}, func(err error) {
+		if titleSource.Instance != "" {
+			fmt.Fprintf(stderr, "%s: %s\n", titleSource.Instance, err)
+		} else {
+			fmt.Fprintln(stderr, err)
+		}
+	})
+}
+
+// Flip privacy mode every time a signal arrives, sending a change to be
+// sampled so the titles disappear, or come back, right away.
+func togglePrivacyOnSignal(stderr io.Writer, signals <-chan os.Signal, private *atomic.Bool, titleChangeEvents chan interface{}) {
+	for range signals {
+		if togglePrivacy(private) {
+			fmt.Fprintln(stderr, "Privacy mode on, hiding all titles")
+		} else {
+			fmt.Fprintln(stderr, "Privacy mode off")
+		}
+		titleChangeEvents <- "privacy"
+	}
+}
+
+// Flip the given privacy mode, returning whether it's now on.
+func togglePrivacy(private *atomic.Bool) bool {
+	for {
+		on := private.Load()
+		if private.CompareAndSwap(on, !on) {
+			return !on
+		}
+	}
+}
+
 func main() {
 	stdin := os.Stdin
 	stdout := os.Stdout
 	stderr := os.Stderr
 
+	if len(os.Args) > 1 {
+		if subcommand, found := subcommands[os.Args[1]]; found {
+			os.Exit(subcommand(stdout, stderr, os.Args[1:]))
+		}
+	}
+
 	config, err := newConfig(os.Args[0], os.Args[1:])
 	exit, code := shouldExit(stdout, config, err)
 	if exit {
@@ -127,8 +490,8 @@ func main() {
 		os.Exit(MissingStatusProcessErrorCode)
 	}
 
-	// This window.API is for the current X11 display.
-	windowAPI, err := window.NewX11()
+	// Each title source has a window.API for an X11 display or window manager.
+	titleSources, err := newTitleSources(stderr, config)
 	if err != nil {
 		// any display error on creation is fatal
 		fmt.Fprintln(stderr, err)
@@ -136,25 +499,69 @@ func main() {
 	}
 
 	// Changes are sampled and an update for i3status is only done every
-	// titleChangeSampleMs milliseconds instead of every time X11 decides to
+	// --sample-interval milliseconds instead of every time X11 decides to
 	// change a property. This minimizes signal sending to i3status which forces
 	// an update to everything it may be polling.
+	stats := &stats{started: time.Now()}
 	titleChangeEvents := make(chan interface{}, titleChangeEventBufferSize)
-	titleChangeSampler := sampler.NewSampler(titleChangeEvents, titleChangeSampleMs)
+	titleChangeSampler := sampler.NewSampler(titleChangeEvents, config.sampleMs)
 	go titleChangeSampler.Run(func(value interface{}) {
+		stats.refreshes.Add(1)
 		process.SignalPidsWithUSR1(currentStatusPids)
 	})
 
 	// Whenever a change to a window title is detected, send it to this channel
-	// to be sampled.
-	go windowAPI.DetectWindowTitleChanges(func() {
-		titleChangeEvents <- "changed"
-	}, func(err error) {
-		fmt.Fprintln(stderr, err)
-	})
+	// to be sampled. Events that would not change what is displayed, like a
+	// window rewriting the same title, are dropped here to avoid forcing an
+	// update of i3status for nothing.
+	for _, titleSource := range titleSources {
+		go detectTitleChanges(stderr, titleSource, stats, titleChangeEvents)
+	}
+
+	// SIGUSR2 flips privacy mode, hiding every title until it's flipped back.
+	private := &atomic.Bool{}
+	privacySignals := make(chan os.Signal, 1)
+	signal.Notify(privacySignals, syscall.SIGUSR2)
+	go togglePrivacyOnSignal(stderr, privacySignals, private, titleChangeEvents)
+
+	// SIGHUP reloads the configuration, and so does changing the file when
+	// it's watched, with the title options taking effect on the next line.
+	options := config.i3Options()
+	options.Private, options.Override = private, &atomic.Pointer[i3.Override]{}
+	live := i3.NewLiveOptions(options)
+	reloadReasons := make(chan string, 1)
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	go reloadOnSignal(reloadSignals, reloadReasons)
+	if config.watchConfig {
+		go reloadOnChange(stderr, config.configPath, reloadReasons)
+	}
+	go reloadOnRequest(stderr, reloadReasons, os.Args[0], os.Args[1:], config, live, stats, titleChangeEvents)
+
+	// The control socket answers the ctl subcommand, so keybindings can query
+	// and change what's shown. Another instance already answering on it, like
+	// for a second bar, isn't fatal.
+	var controlListener net.Listener
+	if config.controlSocket != "" {
+		controlListener, err = control.Listen(config.controlSocket)
+		if err != nil {
+			fmt.Fprintf(stderr, "No control socket at %s: %s\n", config.controlSocket, err)
+		} else {
+			controller := &controller{live: live, sources: titleSources, stats: stats, titleChangeEvents: titleChangeEvents}
+			go control.Serve(controlListener, controller.handle, control.DefaultIdleTimeout, func(err error) {
+				if !errors.Is(err, net.ErrClosed) {
+					fmt.Fprintf(stderr, "Control socket: %s\n", err)
+				}
+			})
+		}
+	}
 
 	// With everything set up and running, start processing the output from
 	// i3status and injecting the window titles.
-	exitCode := i3.RunJSONParsingLoop(stdin, stdout, stderr, windowAPI, config.color, config.appendEnd, config.fixedWidth)
+	counter := &statusLineCounter{writer: stdout, lines: &stats.statusLines}
+	exitCode := i3.RunLive(stdin, counter, stderr, titleSources, live)
+	if controlListener != nil {
+		controlListener.Close()
+	}
 	os.Exit(exitCode)
 }
@@ -15,11 +15,20 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
 )
 
 func TestCliNoArgs(t *testing.T) {
+	isolateSettings(t)
 	args := []string{}
 	config, err := newConfig("test", args)
 	if err != nil {
@@ -34,6 +43,9 @@ func TestCliNoArgs(t *testing.T) {
 	if config.fixedWidth != 0 {
 		t.Fatal("Unexpected fixedWidth default")
 	}
+	if config.backend != "auto" {
+		t.Fatal("Unexpected backend default")
+	}
 	if config.printHelp {
 		t.Fatal("Unexpected printHelp default")
 	}
@@ -51,6 +63,7 @@ func TestCliNoArgs(t *testing.T) {
 }
 
 func TestCliVersionArgs(t *testing.T) {
+	isolateSettings(t)
 	args := []string{"--version"}
 	config, err := newConfig("test", args)
 	if err != nil {
@@ -82,6 +95,7 @@ func TestCliVersionArgs(t *testing.T) {
 }
 
 func TestCliHelpArgs(t *testing.T) {
+	isolateSettings(t)
 	args := []string{"--help"}
 	config, err := newConfig("test", args)
 	if err != nil {
@@ -113,6 +127,7 @@ func TestCliHelpArgs(t *testing.T) {
 }
 
 func TestCliBadArgs(t *testing.T) {
+	isolateSettings(t)
 	args := []string{"--bad-args"}
 	config, err := newConfig("test", args)
 	if err == nil {
@@ -142,3 +157,212 @@ func TestCliBadArgs(t *testing.T) {
 		t.Fatal("Unexpected exit code")
 	}
 }
+
+func TestCliBackendArgs(t *testing.T) {
+	isolateSettings(t)
+	args := []string{"--backend", "i3ipc"}
+	config, err := newConfig("test", args)
+	if err != nil {
+		t.Fatal("Unexpected error")
+	}
+	if config.backend != "i3ipc" {
+		t.Fatal("Expected i3ipc backend")
+	}
+
+	exit, code := shouldExit(ioutil.Discard, config, err)
+	if exit {
+		t.Fatal("Unexpected exit")
+	}
+	if code != 0 {
+		t.Fatal("Unexpected exit code")
+	}
+}
+
+func TestCliUnknownBackendArgs(t *testing.T) {
+	isolateSettings(t)
+	args := []string{"--backend", "potato"}
+	config, err := newConfig("test", args)
+	if err != nil {
+		t.Fatal("Unexpected error")
+	}
+
+	exit, code := shouldExit(ioutil.Discard, config, err)
+	if !exit {
+		t.Fatal("Expected exit")
+	}
+	if code != BadConfigErrorCode {
+		t.Fatal("Unexpected exit code")
+	}
+}
+
+func TestCliDisplayArgs(t *testing.T) {
+	isolateSettings(t)
+	args := []string{"--display", ":0,:1"}
+	config, err := newConfig("test", args)
+	if err != nil {
+		t.Fatal("Unexpected error")
+	}
+	if len(config.displays) != 2 || config.displays[0] != ":0" || config.displays[1] != ":1" {
+		t.Fatalf("Unexpected displays %v", config.displays)
+	}
+}
+
+func TestCliDisplayWithOtherBackends(t *testing.T) {
+	isolateSettings(t)
+	for _, backend := range []string{"x11", "auto"} {
+		if _, err := newConfig("test", []string{"--backend", backend, "--display", ":0,:1"}); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", backend, err)
+		}
+	}
+	for _, backend := range []string{"sway", "i3ipc", "hyprland", "fake"} {
+		config, err := newConfig("test", []string{"--backend", backend, "--display", ":0,:1"})
+		if err == nil {
+			t.Fatalf("Expected --display to be rejected with %s", backend)
+		}
+		if _, code := shouldExit(ioutil.Discard, config, err); code != BadConfigErrorCode {
+			t.Fatalf("Expected bad config code for %s, got %d", backend, code)
+		}
+	}
+}
+
+func TestCliOutputWithOtherBackends(t *testing.T) {
+	isolateSettings(t)
+	for _, backend := range []string{"sway", "i3ipc", "hyprland", "fake"} {
+		if _, err := newConfig("test", []string{"--backend", backend, "--output", "DP-1"}); err == nil {
+			t.Fatalf("Expected --output to be rejected with %s", backend)
+		}
+	}
+	if _, err := newConfig("test", []string{"--backend", "x11", "--output", "DP-1"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutputIgnoredWarning(t *testing.T) {
+	// detected before everything else, but only in this test
+	window.Register(window.Backend{
+		Name:     "detected",
+		Priority: -1,
+		Probe: func(options window.Options) (string, error) {
+			if options.Output != "DP-1" {
+				return "", errors.New("not this test")
+			}
+			return "this test", nil
+		},
+		Open: func(window.Options) (window.API, error) {
+			return failingWindowAPI{}, nil
+		},
+	})
+
+	var stderr bytes.Buffer
+	_, err := newWindowAPI(&stderr, window.AutoBackend, window.Options{Output: "DP-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "Ignoring --output DP-1") {
+		t.Fatalf("Expected a warning about --output, got %q", stderr.String())
+	}
+}
+
+func TestCliIgnoreArgs(t *testing.T) {
+	isolateSettings(t)
+	args := []string{"--ignore-class", "rofi,dmenu", "--ignore-type", "dock", "--ignore-title", "^Picture"}
+	config, err := newConfig("test", args)
+	if err != nil {
+		t.Fatal("Unexpected error")
+	}
+	if len(config.ignoreRules.Classes) != 2 || len(config.ignoreRules.Types) != 1 || len(config.ignoreRules.Titles) != 1 {
+		t.Fatalf("Unexpected ignore rules %+v", config.ignoreRules)
+	}
+	if len(config.ignoreRules.Roles) != 0 {
+		t.Fatal("Unexpected ignore roles")
+	}
+}
+
+func TestCliBadIgnoreTitleArgs(t *testing.T) {
+	isolateSettings(t)
+	args := []string{"--ignore-title", "(potato"}
+	config, err := newConfig("test", args)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	exit, code := shouldExit(ioutil.Discard, config, err)
+	if !exit {
+		t.Fatal("Expected exit")
+	}
+	if code != BadConfigErrorCode {
+		t.Fatal("Unexpected exit code")
+	}
+}
+
+func TestCliBadgeArgs(t *testing.T) {
+	isolateSettings(t)
+	args := []string{"--badges", "fullscreen=F,sticky=", "--badge-blocks", "fullscreen"}
+	config, err := newConfig("test", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	glyphs := config.titleOptions.BadgeGlyphs
+	if len(glyphs) != 2 || glyphs["fullscreen"] != "F" || glyphs["sticky"] != "" {
+		t.Fatalf("Unexpected badge glyphs %v", glyphs)
+	}
+	if len(config.titleOptions.BadgeBlocks) != 1 {
+		t.Fatalf("Unexpected badge blocks %v", config.titleOptions.BadgeBlocks)
+	}
+}
+
+func TestCliBadBadgeArgs(t *testing.T) {
+	isolateSettings(t)
+	for _, args := range [][]string{
+		{"--badges", "fullscreen"},
+		{"--badges", "potato=P"},
+		{"--badge-blocks", "potato"},
+	} {
+		if _, err := newConfig("test", args); err == nil {
+			t.Fatalf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestCliConfigArgs(t *testing.T) {
+	isolateSettings(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"icons": {"firefox": "B"}, "default_icon": "W"}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := newConfig("test", []string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.titleOptions.Icons["firefox"] != "B" || config.titleOptions.DefaultIcon != "W" {
+		t.Fatalf("Unexpected title options %+v", config.titleOptions)
+	}
+}
+
+func TestCliBadConfigArgs(t *testing.T) {
+	isolateSettings(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"icons": "potato"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := newConfig("test", []string{"--config", path})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	exit, code := shouldExit(ioutil.Discard, config, err)
+	if !exit || code != BadConfigErrorCode {
+		t.Fatal("Expected exit with bad config code")
+	}
+}
+
+func TestTogglePrivacy(t *testing.T) {
+	private := &atomic.Bool{}
+	if !togglePrivacy(private) || !private.Load() {
+		t.Fatal("Expected privacy mode on")
+	}
+	if togglePrivacy(private) || private.Load() {
+		t.Fatal("Expected privacy mode off")
+	}
+}
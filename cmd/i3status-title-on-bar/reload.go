@@ -0,0 +1,111 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	configfile "github.com/rholder/i3status-title-on-bar/pkg/config"
+	"github.com/rholder/i3status-title-on-bar/pkg/i3"
+)
+
+// Reload the configuration every time a reason to do so arrives, storing the new
+// title options for the next status line and sending a change to be sampled
+// so it comes right away. Privacy mode and the override text are kept as they
+// are. A configuration with mistakes is reported and the running one is kept.
+func reloadOnRequest(stderr io.Writer, reasons <-chan string, name string, args []string,
+	running *Config, live *i3.LiveOptions, stats *stats, titleChangeEvents chan interface{}) {
+
+	for reason := range reasons {
+		config, err := newConfig(name, args)
+		if err != nil {
+			fmt.Fprintf(stderr, "Configuration reload on %s failed, keeping the running one: %s\n", reason, err)
+			continue
+		}
+		live.Update(func(options *i3.Options) {
+			reloaded := config.i3Options()
+			reloaded.Private, reloaded.Override = options.Private, options.Override
+			*options = reloaded
+		})
+		stats.reloads.Add(1)
+		fmt.Fprintf(stderr, "Configuration reloaded on %s\n", reason)
+		if restart := restartNeeded(running, config); len(restart) > 0 {
+			fmt.Fprintf(stderr, "Changes to %s need a restart\n", strings.Join(restart, ", "))
+		}
+		titleChangeEvents <- "reload"
+	}
+}
+
+// Ask for a reload without waiting, since one that's already waiting covers it.
+func requestReload(reasons chan<- string, reason string) {
+	select {
+	case reasons <- reason:
+	default:
+	}
+}
+
+// Request a reload every time the given signal arrives.
+func reloadOnSignal(signals <-chan os.Signal, reasons chan<- string) {
+	for signal := range signals {
+		requestReload(reasons, signal.String())
+	}
+}
+
+// Request a reload every time the configuration file at the given path
+// changes, reporting when it can't be watched.
+func reloadOnChange(stderr io.Writer, path string, reasons chan<- string) {
+	err := configfile.Watch(path, func() {
+		requestReload(reasons, "change of "+path)
+	})
+	fmt.Fprintf(stderr, "Stopped watching %s: %s\n", path, err)
+}
+
+// Return the options changed between the given configurations that only take
+// effect on startup, since they pick and wrap the window backends.
+func restartNeeded(running *Config, reloaded *Config) []string {
+	var changed []string
+	if running.backend != reloaded.backend {
+		changed = append(changed, "--backend")
+	}
+	if running.fakeScript != reloaded.fakeScript {
+		changed = append(changed, "--fake-script")
+	}
+	if running.output != reloaded.output {
+		changed = append(changed, "--output")
+	}
+	if !slices.Equal(running.displays, reloaded.displays) {
+		changed = append(changed, "--display")
+	}
+	if fmt.Sprint(running.ignoreRules) != fmt.Sprint(reloaded.ignoreRules) {
+		changed = append(changed, "--ignore-*")
+	}
+	if running.focusDwellMs != reloaded.focusDwellMs {
+		changed = append(changed, "--focus-dwell")
+	}
+	if running.sampleMs != reloaded.sampleMs {
+		changed = append(changed, "--sample-interval")
+	}
+	if running.watchConfig != reloaded.watchConfig {
+		changed = append(changed, "--watch-config")
+	}
+	if running.controlSocket != reloaded.controlSocket {
+		changed = append(changed, "--control-socket")
+	}
+	return changed
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/i3"
+)
+
+func TestReloadOnRequest(t *testing.T) {
+	isolateSettings(t)
+	path := writeConfig(t, filepath.Join(t.TempDir(), "config.json"), `{"color": "#111111"}`)
+	args := []string{"--config", path, "--format", "[{title}]"}
+	running, err := newConfig("test", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := running.i3Options()
+	options.Private = &atomic.Bool{}
+	live := i3.NewLiveOptions(options)
+	stats := &stats{}
+
+	var stderr bytes.Buffer
+	titleChangeEvents := make(chan interface{}, 10)
+	reload := func(content string) {
+		t.Helper()
+		writeConfig(t, path, content)
+		reasons := make(chan string, 1)
+		reasons <- "hangup"
+		close(reasons)
+		reloadOnRequest(&stderr, reasons, "test", args, running, live, stats, titleChangeEvents)
+	}
+
+	reload(`{"color": "#222222", "fixed_width": 20}`)
+	if event := <-titleChangeEvents; event != "reload" {
+		t.Fatalf("Expected a reload change, got %v", event)
+	}
+	reloadedOptions := live.Load()
+	if reloadedOptions.Color != "#222222" || reloadedOptions.FixedWidth != 20 || reloadedOptions.Format != "[{title}]" ||
+		reloadedOptions.Private != options.Private {
+		t.Fatalf("Expected reloaded options, got %+v", reloadedOptions)
+	}
+
+	// mistakes keep the running configuration
+	reload(`{"color": "#333333", "colour": "#333333"}`)
+	if color := live.Load().Color; color != "#222222" || len(titleChangeEvents) != 0 {
+		t.Fatalf("Expected the running configuration, got %s", color)
+	}
+	reload(`{"color": "#444444", "backend": "fake"}`)
+
+	expected := []string{
+		"Configuration reloaded on hangup",
+		`Configuration reload on hangup failed, keeping the running one: config file ` + path + `: line 1: unknown setting "colour"`,
+		"Configuration reloaded on hangup",
+		"Changes to --backend need a restart",
+	}
+	if lines := strings.Split(strings.TrimSpace(stderr.String()), "\n"); strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected %q, got %q", expected, lines)
+	}
+	if color := live.Load().Color; color != "#444444" || stats.reloads.Load() != 2 {
+		t.Fatalf("Expected the reloaded configuration, got %s", color)
+	}
+}
+
+func TestRequestReloadDoesNotWait(t *testing.T) {
+	reasons := make(chan string, 1)
+	requestReload(reasons, "first")
+	requestReload(reasons, "second")
+	if reason := <-reasons; reason != "first" {
+		t.Fatalf("Expected the waiting reload, got %s", reason)
+	}
+}
+
+func TestRestartNeeded(t *testing.T) {
+	isolateSettings(t)
+	running, _ := newConfig("test", []string{"--color", "#FFFFFF"})
+	reloaded, _ := newConfig("test", []string{"--color", "#000000", "--display", ":1", "--ignore-title", "^x$", "--sample-interval", "50"})
+	expected := "--display, --ignore-*, --sample-interval"
+	if changed := strings.Join(restartNeeded(running, reloaded), ", "); changed != expected {
+		t.Fatalf("Expected %s, got %s", expected, changed)
+	}
+	if changed := restartNeeded(running, running); len(changed) != 0 {
+		t.Fatalf("Expected no changes, got %v", changed)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	configfile "github.com/rholder/i3status-title-on-bar/pkg/config"
+)
+
+// Every option can also be set with an environment variable named after it
+// with this prefix, like I3STATUS_TITLE_ON_BAR_FIXED_WIDTH for --fixed-width.
+const envPrefix = "I3STATUS_TITLE_ON_BAR_"
+
+// These options only make sense on the command line, or in the environment
+// for config, and are rejected in a configuration file.
+var commandLineOnly = []string{"config", "help", "version"}
+
+// Set the options of the given flag set from the configuration file, then the
+// environment, then the command line arguments, each overriding the one
+// before. The configuration file is the one given with --config, or else the
+// first one found in the XDG configuration directories. It's returned, or nil
+// when there is none. With --help or --version only the command line is used,
+// so a broken configuration file or environment can't get in their way.
+func layerSettings(fs *flag.FlagSet, args []string) (*configfile.File, error) {
+	if boolArg(args, "help") || boolArg(args, "version") {
+		return nil, fs.Parse(args)
+	}
+
+	var file *configfile.File
+	if path := configPath(args); path != "" {
+		var err error
+		file, err = configfile.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyFileSettings(fs, file); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(option *flag.Flag) {
+		name := envName(option.Name)
+		if value, found := os.LookupEnv(name); found && err == nil {
+			if setErr := fs.Set(option.Name, value); setErr != nil {
+				err = fmt.Errorf("environment variable %s: invalid value %q: %v", name, value, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return file, fs.Parse(args)
+}
+
+// Set the options of the given flag set from the settings of the given file,
+// where names can use underscores instead of dashes.
+func applyFileSettings(fs *flag.FlagSet, file *configfile.File) error {
+	for _, key := range slices.Sorted(maps.Keys(file.Settings)) {
+		setting := file.Settings[key]
+		name := strings.ReplaceAll(key, "_", "-")
+		var err error
+		if fs.Lookup(name) == nil || slices.Contains(commandLineOnly, name) {
+			err = fmt.Errorf("unknown setting %q", key)
+		} else if setErr := fs.Set(name, setting.Value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", setting.Value, key, setErr)
+		}
+		if err != nil {
+			return &configfile.Error{Path: file.Path, Line: setting.Line, Err: err}
+		}
+	}
+	return nil
+}
+
+// Find the configuration file to use, given with --config on the command line
+// or in the environment, or else the default one. An empty --config turns the
+// default one off.
+func configPath(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	if path, found := os.LookupEnv(envName("config")); found {
+		return path
+	}
+	return configfile.DefaultPath()
+}
+
+// Report whether the named boolean option is turned on by the given command
+// line arguments.
+func boolArg(args []string, name string) bool {
+	on := false
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		option, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || option != name {
+			continue
+		}
+		on = !hasValue
+		if hasValue {
+			on, _ = strconv.ParseBool(value)
+		}
+	}
+	return on
+}
+
+// Return the environment variable for the named option.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	configfile "github.com/rholder/i3status-title-on-bar/pkg/config"
+)
+
+func writeConfig(t *testing.T, path string, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Keep the configuration files and environment variables of whoever runs the
+// tests from changing the options the given test expects.
+func isolateSettings(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XDG_CONFIG_DIRS", dir)
+	for _, variable := range os.Environ() {
+		if name, _, _ := strings.Cut(variable, "="); strings.HasPrefix(name, envPrefix) {
+			// set first so it's restored after the test
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestSettingsPrecedence(t *testing.T) {
+	isolateSettings(t)
+	path := writeConfig(t, filepath.Join(t.TempDir(), "config.json"), `{
+		"color": "#111111",
+		"format": "{class}",
+		"fixed_width": 10,
+		"append_end": true,
+		"ignore_class": ["rofi", "dmenu"]
+	}`)
+	t.Setenv("I3STATUS_TITLE_ON_BAR_FORMAT", "{instance}")
+	t.Setenv("I3STATUS_TITLE_ON_BAR_FIXED_WIDTH", "20")
+
+	config, err := newConfig("test", []string{"--config", path, "--fixed-width", "30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.color != "#111111" || !config.appendEnd || len(config.ignoreRules.Classes) != 2 {
+		t.Fatalf("Expected settings from the file, got %+v", config)
+	}
+	if config.titleOptions.Format != "{instance}" {
+		t.Fatalf("Expected the environment to override the file, got %s", config.titleOptions.Format)
+	}
+	if config.fixedWidth != 30 {
+		t.Fatalf("Expected the command line to override the environment, got %d", config.fixedWidth)
+	}
+}
+
+func TestSettingsDefaultConfigFile(t *testing.T) {
+	isolateSettings(t)
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	writeConfig(t, filepath.Join(home, configfile.FileName), `{"color": "#222222", "sample_interval": 250}`)
+
+	config, err := newConfig("test", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.color != "#222222" || config.sampleMs != 250 {
+		t.Fatalf("Expected settings from the default file, got %+v", config)
+	}
+
+	// an empty --config leaves the default one out
+	config, err = newConfig("test", []string{"--config="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.color != defaultColor || config.sampleMs != titleChangeSampleMs {
+		t.Fatalf("Expected default settings, got %+v", config)
+	}
+}
+
+func TestSettingsConfigFromEnvironment(t *testing.T) {
+	isolateSettings(t)
+	path := writeConfig(t, filepath.Join(t.TempDir(), "config.json"), `{"color": "#333333"}`)
+	t.Setenv("I3STATUS_TITLE_ON_BAR_CONFIG", path)
+
+	config, err := newConfig("test", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.color != "#333333" {
+		t.Fatalf("Expected settings from %s, got %+v", path, config)
+	}
+}
+
+func TestSettingsErrors(t *testing.T) {
+	isolateSettings(t)
+	dir := t.TempDir()
+	configs := map[string]string{
+		"{\n\"color\": \"#FFF\",\n\"colour\": \"#FFF\"\n}": `line 3: unknown setting "colour"`,
+		"{\n\"fixed_width\": \"wide\"\n}":                  `line 2: invalid value "wide" for fixed_width`,
+		"{\n\"help\": true\n}":                             `line 2: unknown setting "help"`,
+		"{\n\"format\": \"{title}\"\n\"color\": 1\n}":      "line 3: invalid character",
+	}
+	for content, expected := range configs {
+		path := writeConfig(t, filepath.Join(dir, "config.json"), content)
+		config, err := newConfig("test", []string{"--config", path})
+		if err == nil || !strings.HasPrefix(err.Error(), "config file "+path+": "+expected) {
+			t.Fatalf("Expected %q for %q, got %v", expected, content, err)
+		}
+		if exit, code := shouldExit(ioutil.Discard, config, err); !exit || code != BadConfigErrorCode {
+			t.Fatalf("Expected exit with bad config code for %q", content)
+		}
+	}
+
+	t.Setenv("I3STATUS_TITLE_ON_BAR_FOCUS_DWELL", "long")
+	_, err := newConfig("test", []string{})
+	if err == nil || !strings.HasPrefix(err.Error(), "environment variable I3STATUS_TITLE_ON_BAR_FOCUS_DWELL: ") {
+		t.Fatalf("Expected error naming the environment variable, got %v", err)
+	}
+}
+
+func TestSettingsIgnoredForHelpAndVersion(t *testing.T) {
+	isolateSettings(t)
+	t.Setenv("I3STATUS_TITLE_ON_BAR_FOCUS_DWELL", "long")
+	path := writeConfig(t, filepath.Join(t.TempDir(), "config.json"), `{"color": `)
+	t.Setenv("I3STATUS_TITLE_ON_BAR_CONFIG", path)
+
+	for _, args := range [][]string{{"--help"}, {"-version"}, {"--version=true", "--color", "#FFFFFF"}} {
+		config, err := newConfig("test", args)
+		if err != nil {
+			t.Fatalf("Expected no error for %v, got %v", args, err)
+		}
+		if !config.printHelp && !config.printVersion {
+			t.Fatalf("Expected help or version for %v", args)
+		}
+	}
+
+	if _, err := newConfig("test", []string{"--help=false"}); err == nil {
+		t.Fatal("Expected the settings to be used without help")
+	}
+}
+
+func TestSettingsBadSampleInterval(t *testing.T) {
+	isolateSettings(t)
+	if _, err := newConfig("test", []string{"--sample-interval", "0"}); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	isolateSettings(t)
+	paths := map[string][]string{
+		"a.json": {"--config", "a.json"},
+		"b.json": {"--color", "#FFF", "-config=b.json"},
+		"c.json": {"-config", "c.json", "--fixed-width", "3"},
+		"":       {"--config", ""},
+	}
+	for expected, args := range paths {
+		if path := configPath(args); path != expected {
+			t.Fatalf("Expected %q for %v, got %q", expected, args, path)
+		}
+	}
+	if path := configPath([]string{"--", "--config", "d.json"}); path != "" {
+		t.Fatalf("Expected no configuration file after --, got %q", path)
+	}
+}
+
+func TestSettingsWatchConfigNeedsFile(t *testing.T) {
+	isolateSettings(t)
+	if _, err := newConfig("test", []string{"--watch-config"}); err == nil {
+		t.Fatal("Expected error")
+	}
+	path := writeConfig(t, filepath.Join(t.TempDir(), "config.json"), `{"watch_config": true}`)
+	config, err := newConfig("test", []string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.watchConfig || config.configPath != path {
+		t.Fatalf("Expected to watch %s, got %+v", path, config)
+	}
+}
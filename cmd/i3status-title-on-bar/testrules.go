@@ -0,0 +1,111 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	configfile "github.com/rholder/i3status-title-on-bar/pkg/config"
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+const testRulesHelpText = `Usage: i3status-title-on-bar test-rules [OPTIONS...] TITLE
+
+  Show how the rules of a configuration file transform the given window title,
+  one rule at a time, whether it's masked and which color rule styles it,
+  without needing a display.
+
+Options:
+  --config [path]          JSON configuration file with the rules to test (Defaults to
+                           the one found in the XDG configuration directories)
+  --class [class]          Window class to test the title with
+  --instance [instance]    Window instance to test the title with
+  --help                   Print this help text and exit
+
+Examples:
+  i3status-title-on-bar test-rules --config config.json --class firefox 'GitHub — Mozilla Firefox'`
+
+// Run the test-rules subcommand with the given arguments, returning the exit
+// code.
+func runTestRules(stdout io.Writer, stderr io.Writer, args []string) int {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		configPath = fs.String("config", "", "Path to a JSON configuration file")
+		class      = fs.String("class", "", "Window class to test the title with")
+		instance   = fs.String("instance", "", "Window instance to test the title with")
+		printHelp  = fs.Bool("help", false, "Print additional help text and exit")
+	)
+	// disable default output
+	fs.SetOutput(ioutil.Discard)
+	err := fs.Parse(args[1:])
+	if err == nil && fs.NArg() == 0 && !*printHelp {
+		err = fmt.Errorf("missing the title to test")
+	}
+	if err != nil {
+		fmt.Fprintln(stdout, err.Error()+"\n")
+		fmt.Fprintln(stdout, testRulesHelpText)
+		return BadConfigErrorCode
+	}
+	if *printHelp {
+		fmt.Fprintln(stdout, testRulesHelpText)
+		return PrintErrorCode
+	}
+
+	file := &configfile.File{}
+	if *configPath == "" {
+		*configPath = configfile.DefaultPath()
+	}
+	if *configPath != "" {
+		file, err = configfile.Load(*configPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return BadConfigErrorCode
+		}
+	}
+
+	info := window.Info{Title: strings.Join(fs.Args(), " "), Class: *class, Instance: *instance}
+	fmt.Fprintf(stdout, "title:   %q\n", info.Title)
+	for i, step := range file.Rewrites().Trace(info) {
+		rule := fmt.Sprintf("%q -> %q", step.Rule.Pattern.String(), step.Rule.Replacement)
+		if step.Rule.Class != "" {
+			rule += " for class " + step.Rule.Class
+		}
+		if !step.Applied {
+			fmt.Fprintf(stdout, "rule %d:  %s, skipped\n", i+1, rule)
+			continue
+		}
+		fmt.Fprintf(stdout, "rule %d:  %s\n         %q\n", i+1, rule, step.Title)
+	}
+	fmt.Fprintf(stdout, "result:  %q\n", file.Rewrites().Apply(info))
+
+	masks := file.MaskRules()
+	if text, masked := masks.Text(info, file.MaskText); masked {
+		fmt.Fprintf(stdout, "masked:  mask rule %d, shown as %q\n", masks.Find(info)+1, text)
+	}
+
+	colors := file.ColorRules()
+	if found := colors.Find(info); found >= 0 {
+		color := colors[found]
+		fmt.Fprintf(stdout, "colors:  color rule %d, color %q background %q border %q\n",
+			found+1, color.Color, color.Background, color.Border)
+	} else if len(colors) > 0 {
+		fmt.Fprintln(stdout, "colors:  no color rule matches")
+	}
+	return 0
+}
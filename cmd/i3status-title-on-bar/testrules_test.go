@@ -0,0 +1,73 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTestRules(t *testing.T) {
+	isolateSettings(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"rewrite": [
+		{"class": "firefox", "match": " — Mozilla Firefox$"},
+		{"class": "code", "match": " - Visual Studio Code$"},
+		{"match": "^GitHub - (.*)$", "replace": "GH $1"}
+	], "masks": [
+		{"class": "firefox", "title": "issues", "text": "work"}
+	], "colors": [
+		{"class": "code", "color": "#0000FF"},
+		{"title": "issues", "background": "#CC0000"}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"test-rules", "--config", path, "--class", "firefox", "GitHub", "-", "issues", "—", "Mozilla", "Firefox"}
+	if code := runTestRules(&stdout, &stderr, args); code != 0 {
+		t.Fatalf("Unexpected exit code %d: %s", code, stderr.String())
+	}
+	expected := `title:   "GitHub - issues — Mozilla Firefox"
+rule 1:  " — Mozilla Firefox$" -> "" for class firefox
+         "GitHub - issues"
+rule 2:  " - Visual Studio Code$" -> "" for class code, skipped
+rule 3:  "^GitHub - (.*)$" -> "GH $1"
+         "GH issues"
+result:  "GH issues"
+masked:  mask rule 1, shown as "work"
+colors:  color rule 2, color "" background "#CC0000" border ""
+`
+	if stdout.String() != expected {
+		t.Fatalf("Unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestTestRulesBadArgs(t *testing.T) {
+	isolateSettings(t)
+	var stdout, stderr bytes.Buffer
+	if code := runTestRules(&stdout, &stderr, []string{"test-rules"}); code != BadConfigErrorCode {
+		t.Fatalf("Expected bad config for a missing title, got %d", code)
+	}
+	if code := runTestRules(&stdout, &stderr, []string{"test-rules", "--config", "/nonexistent.json", "x"}); code != BadConfigErrorCode {
+		t.Fatalf("Expected bad config for a missing file, got %d", code)
+	}
+	if code := runTestRules(&stdout, &stderr, []string{"test-rules", "--help"}); code != PrintErrorCode {
+		t.Fatalf("Expected help, got %d", code)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/i3"
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+const watchHelpText = `Usage: i3status-title-on-bar watch [OPTIONS...]
+
+  Print a JSON object on a line of its own for the active window, and again
+  every time the focus moves to another window or the active window changes
+  its title, without needing i3status. Each object has the time, the event
+  (focus or title), and the id, class, instance, title and pid of the window,
+  with an id of 0 when no window is active. It exits with 12 when the output
+  can't be written, like once the reader is gone, or a backend stops with an
+  error.
+
+Options:
+  The options picking and filtering windows work the same as for the bar,
+  like --backend, --display, --output, --fake-script, --ignore-class and the
+  other --ignore options, --focus-dwell and --config, with the same
+  configuration file and environment variables. With more than one display,
+  each object names its display as the source.
+
+  --help                   Print this help text and exit
+
+Examples:
+  i3status-title-on-bar watch
+  i3status-title-on-bar watch --backend i3ipc --ignore-class rofi | jq -r .title`
+
+// A focus or title change of the active window, as printed by watch.
+type watchEvent struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source,omitempty"`
+	Event    string    `json:"event"`
+	ID       uint64    `json:"id"`
+	Class    string    `json:"class"`
+	Instance string    `json:"instance"`
+	Title    string    `json:"title"`
+	PID      int       `json:"pid"`
+}
+
+// Prints a watchEvent whenever the active window of a title source is another
+// one, or has another title, than the last time it was printed. The first
+// error printing one is kept in writeErr, closing stopped.
+type windowWatcher struct {
+	mutex    sync.Mutex
+	encoder  *json.Encoder
+	stderr   io.Writer
+	now      func() time.Time
+	last     map[string]window.Info
+	writeErr error
+	stopped  chan struct{}
+}
+
+// Run the watch subcommand with the given arguments, returning the exit code
+// once every title source stops, which only the fake backend ever does unless
+// a backend fails, or once the output can't be written anymore.
+func runWatch(stdout io.Writer, stderr io.Writer, args []string) int {
+	config, err := newConfig(args[0], args[1:])
+	if err == nil && config.backend != window.AutoBackend {
+		if _, found := window.DefaultRegistry.Lookup(config.backend); !found {
+			err = fmt.Errorf("unknown backend: %s", config.backend)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stdout, err.Error()+"\n")
+		fmt.Fprintln(stdout, watchHelpText)
+		return BadConfigErrorCode
+	}
+	if config.printHelp {
+		fmt.Fprintln(stdout, watchHelpText)
+		return PrintErrorCode
+	}
+
+	titleSources, err := newTitleSources(stderr, config)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return BadDisplayErrorCode
+	}
+
+	watcher := newWindowWatcher(stdout, stderr)
+	var wait sync.WaitGroup
+	var failed atomic.Bool
+	for _, titleSource := range titleSources {
+		watcher.changed(titleSource)
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			err := titleSource.API.DetectWindowTitleChanges(func(window.Event) {
+				watcher.changed(titleSource)
+			}, func(err error) {
+				watcher.report(titleSource, err)
+			})
+			if err != nil {
+				watcher.report(titleSource, err)
+				failed.Store(true)
+			}
+		}()
+	}
+	sourcesStopped := make(chan struct{})
+	go func() {
+		wait.Wait()
+		close(sourcesStopped)
+	}()
+
+	select {
+	case <-watcher.stopped:
+	case <-sourcesStopped:
+	}
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	if watcher.writeErr != nil {
+		fmt.Fprintln(stderr, watcher.writeErr)
+		return WatchFailedErrorCode
+	}
+	if failed.Load() {
+		return WatchFailedErrorCode
+	}
+	return 0
+}
+
+func newWindowWatcher(stdout io.Writer, stderr io.Writer) *windowWatcher {
+	return &windowWatcher{
+		encoder: json.NewEncoder(stdout),
+		stderr:  stderr,
+		now:     time.Now,
+		last:    map[string]window.Info{},
+		stopped: make(chan struct{}),
+	}
+}
+
+// Print the active window of the given title source if it's another one, or
+// has another title, than the last one printed for it.
+func (watcher *windowWatcher) changed(titleSource i3.TitleSource) {
+	info, err := titleSource.API.ActiveWindow()
+	if err != nil && !errors.Is(err, window.ErrNoActiveWindow) {
+		watcher.report(titleSource, err)
+		return
+	}
+
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	if watcher.writeErr != nil {
+		return
+	}
+	last, seen := watcher.last[titleSource.Instance]
+	event := "focus"
+	if seen && last.ID == info.ID {
+		if last.Title == info.Title {
+			return
+		}
+		event = "title"
+	}
+	watcher.last[titleSource.Instance] = info
+	err = watcher.encoder.Encode(watchEvent{
+		Time:     watcher.now(),
+		Source:   titleSource.Instance,
+		Event:    event,
+		ID:       info.ID,
+		Class:    info.Class,
+		Instance: info.Instance,
+		Title:    info.Title,
+		PID:      info.PID,
+	})
+	if err != nil {
+		watcher.writeErr = err
+		close(watcher.stopped)
+	}
+}
+
+// Report an error of the given title source on stderr, naming its instance.
+func (watcher *windowWatcher) report(titleSource i3.TitleSource, err error) {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	if titleSource.Instance != "" {
+		fmt.Fprintf(watcher.stderr, "%s: %s\n", titleSource.Instance, err)
+	} else {
+		fmt.Fprintln(watcher.stderr, err)
+	}
+}
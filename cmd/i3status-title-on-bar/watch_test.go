@@ -0,0 +1,172 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/i3"
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// A writer failing like a pipe nobody reads anymore.
+type brokenPipe struct{}
+
+func (brokenPipe) Write(p []byte) (int, error) {
+	return 0, syscall.EPIPE
+}
+
+// A window backend failing with err every time, like one that lost its
+// connection.
+type failingWindowAPI struct {
+	err error
+}
+
+func (api failingWindowAPI) ActiveWindowTitle() string {
+	return ""
+}
+
+func (api failingWindowAPI) ActiveWindow() (window.Info, error) {
+	return window.Info{}, api.err
+}
+
+func (api failingWindowAPI) DetectWindowTitleChanges(onChange func(window.Event), onError func(error)) error {
+	return api.err
+}
+
+func TestWatch(t *testing.T) {
+	isolateSettings(t)
+	script := writeConfig(t, filepath.Join(t.TempDir(), "demo.fake"), strings.Join([]string{
+		"focus 1 Alacritty vim main.go",
+		"retitle 1 vim i3.go",
+		"state 1 fullscreen",
+		"retitle 1 vim i3.go",
+		"workspace 2:web",
+		"focus 2 rofi rofi",
+		"focus 3 firefox GitHub",
+		"close 3",
+	}, "\n"))
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := runWatch(&stdout, &stderr, []string{"watch", "--backend", "fake", "--fake-script", script, "--ignore-class", "rofi"})
+	if code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+
+	var lines []string
+	decoder := json.NewDecoder(&stdout)
+	for decoder.More() {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Time.IsZero() {
+			t.Fatal("Expected the time of the event")
+		}
+		lines = append(lines, strings.Join([]string{event.Event, event.Class, event.Title}, " "))
+	}
+	expected := []string{
+		"focus  ",
+		"focus Alacritty vim main.go",
+		"title Alacritty vim i3.go",
+		"focus firefox GitHub",
+		// closing the active window leaves none
+		"focus  ",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestWatchEventFormat(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	watcher := newWindowWatcher(&stdout, &stderr)
+	watcher.now = func() time.Time { return time.Date(2019, 7, 4, 12, 30, 0, 0, time.UTC) }
+	windowAPI, err := window.NewFake(strings.NewReader("focus 0x2a Alacritty vim"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	watcher.changed(i3.TitleSource{Instance: ":1", API: windowAPI})
+	watcher.changed(i3.TitleSource{Instance: ":1", API: windowAPI})
+	expected := `{"time":"2019-07-04T12:30:00Z","source":":1","event":"focus","id":42,"class":"Alacritty","instance":"","title":"vim","pid":0}` + "\n"
+	if stdout.String() != expected {
+		t.Fatalf("Expected %s, got %s", expected, stdout.String())
+	}
+
+	watcher.changed(i3.TitleSource{Instance: ":2", API: failingWindowAPI{errors.New("kaboom")}})
+	if stderr.String() != ":2: kaboom\n" || stdout.String() != expected {
+		t.Fatalf("Expected the error reported on stderr, got %q", stderr.String())
+	}
+}
+
+func TestWatchBrokenPipe(t *testing.T) {
+	isolateSettings(t)
+	script := writeConfig(t, filepath.Join(t.TempDir(), "demo.fake"), "focus 1 Alacritty vim\nretitle 1 vim i3.go")
+	var stderr bytes.Buffer
+	code := runWatch(brokenPipe{}, &stderr, []string{"watch", "--backend", "fake", "--fake-script", script})
+	if code != WatchFailedErrorCode {
+		t.Fatalf("Expected a failure once the output is gone, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "broken pipe") {
+		t.Fatalf("Expected the write error on stderr, got %q", stderr.String())
+	}
+}
+
+func TestWatchBackendFailure(t *testing.T) {
+	isolateSettings(t)
+	window.Register(window.Backend{
+		Name:  "broken",
+		Probe: func(window.Options) (string, error) { return "", errors.New("never detected") },
+		Open: func(window.Options) (window.API, error) {
+			return failingWindowAPI{errors.New("connection lost")}, nil
+		},
+	})
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := runWatch(&stdout, &stderr, []string{"watch", "--backend", "broken"})
+	if code != WatchFailedErrorCode {
+		t.Fatalf("Expected a failure when the backend stops, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "connection lost") {
+		t.Fatalf("Expected the backend error on stderr, got %q", stderr.String())
+	}
+}
+
+func TestWatchBadArgs(t *testing.T) {
+	isolateSettings(t)
+	for _, args := range [][]string{
+		{"watch", "--backend", "potato"},
+		{"watch", "--potato"},
+	} {
+		if code := runWatch(ioutil.Discard, ioutil.Discard, args); code != BadConfigErrorCode {
+			t.Fatalf("Expected bad config code for %v, got %d", args, code)
+		}
+	}
+	if code := runWatch(ioutil.Discard, ioutil.Discard, []string{"watch", "--help"}); code != PrintErrorCode {
+		t.Fatalf("Expected print code for help, got %d", code)
+	}
+}
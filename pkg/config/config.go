@@ -0,0 +1,447 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/rules"
+)
+
+// File holds the settings read from a JSON configuration file. Anything left
+// out of the file keeps its zero value.
+type File struct {
+	// Icons maps window classes, or instances, to the glyph shown for them.
+	// Keys are lowercased when read so they match ignoring case.
+	Icons map[string]string `json:"icons"`
+
+	// DefaultIcon is shown for windows without an entry in Icons.
+	DefaultIcon string `json:"default_icon"`
+
+	// Rewrite is the ordered list of rules rewriting window titles.
+	Rewrite []RewriteRule `json:"rewrite"`
+
+	// Colors is the ordered list of rules styling window titles, the first
+	// matching one wins.
+	Colors []ColorRule `json:"colors"`
+
+	// MaskText replaces the titles of masked windows and every title in
+	// privacy mode.
+	MaskText string `json:"mask_text"`
+
+	// Masks is the ordered list of rules hiding window titles.
+	Masks []MaskRule `json:"masks"`
+
+	// Settings holds everything else in the file, the same settings as the
+	// command line options, by name.
+	Settings map[string]Setting `json:"-"`
+
+	// Path is where the file was loaded from, empty when it was parsed from
+	// somewhere else.
+	Path string `json:"-"`
+}
+
+// Setting is the value of a command line option given in a file. Lists are
+// joined with commas, the way they're given on the command line.
+type Setting struct {
+	Value string
+	Line  int
+}
+
+// Error is a mistake in a configuration file along with the line it's on.
+type Error struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (err *Error) Error() string {
+	if err.Path == "" {
+		return fmt.Sprintf("line %d: %v", err.Line, err.Err)
+	}
+	return fmt.Sprintf("config file %s: line %d: %v", err.Path, err.Line, err.Err)
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// RewriteRule replaces every match of a pattern in the title of a window.
+type RewriteRule struct {
+	// Class limits the rule to windows with this class or instance.
+	Class string `json:"class"`
+
+	// Match is the pattern to replace.
+	Match *Pattern `json:"match"`
+
+	// Replace is the replacement, which can refer to capture groups like $1.
+	Replace string `json:"replace"`
+}
+
+// ColorRule styles the title of the windows matching all of its conditions.
+type ColorRule struct {
+	// Class matches the window class.
+	Class string `json:"class"`
+
+	// Instance matches the window instance.
+	Instance string `json:"instance"`
+
+	// Title matches the original window title.
+	Title *Pattern `json:"title"`
+
+	// Color is the text color.
+	Color string `json:"color"`
+
+	// Background is the background color.
+	Background string `json:"background"`
+
+	// Border is the border color.
+	Border string `json:"border"`
+}
+
+// MaskRule hides the title of the windows matching all of its conditions.
+type MaskRule struct {
+	// Class matches the window class or instance.
+	Class string `json:"class"`
+
+	// State matches one of the window states, like hidden.
+	State string `json:"state"`
+
+	// Title matches the original window title.
+	Title *Pattern `json:"title"`
+
+	// Incognito matches titles marking private browser windows.
+	Incognito bool `json:"incognito"`
+
+	// Text is shown instead of the title, MaskText when empty.
+	Text string `json:"text"`
+}
+
+// Pattern is a regular expression compiled while the file is read, so a bad
+// one is reported along with everything else that's wrong with the file.
+type Pattern struct {
+	*regexp.Regexp
+}
+
+// UnmarshalJSON compiles a JSON string into a Pattern.
+func (pattern *Pattern) UnmarshalJSON(data []byte) error {
+	var expression string
+	if err := json.Unmarshal(data, &expression); err != nil {
+		return err
+	}
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return err
+	}
+	pattern.Regexp = compiled
+	return nil
+}
+
+// Rewrites converts the rewrite rules of the file into rules.Rewrites.
+func (file *File) Rewrites() rules.Rewrites {
+	var rewrites rules.Rewrites
+	for _, rule := range file.Rewrite {
+		rewrites = append(rewrites, rules.Rewrite{Class: rule.Class, Pattern: rule.Match.Regexp, Replacement: rule.Replace})
+	}
+	return rewrites
+}
+
+// ColorRules converts the color rules of the file into rules.Colors.
+func (file *File) ColorRules() rules.Colors {
+	var colors rules.Colors
+	for _, rule := range file.Colors {
+		color := rules.Color{
+			Class:      rule.Class,
+			Instance:   rule.Instance,
+			Color:      rule.Color,
+			Background: rule.Background,
+			Border:     rule.Border,
+		}
+		if rule.Title != nil {
+			color.Title = rule.Title.Regexp
+		}
+		colors = append(colors, color)
+	}
+	return colors
+}
+
+// MaskRules converts the mask rules of the file into rules.Masks.
+func (file *File) MaskRules() rules.Masks {
+	var masks rules.Masks
+	for _, rule := range file.Masks {
+		mask := rules.Mask{Class: rule.Class, State: rule.State, Incognito: rule.Incognito, Text: rule.Text}
+		if rule.Title != nil {
+			mask.Title = rule.Title.Regexp
+		}
+		masks = append(masks, mask)
+	}
+	return masks
+}
+
+// FileName is where the configuration file is looked up within the XDG
+// configuration directories.
+const FileName = "i3status-title-on-bar/config.json"
+
+// DefaultPath returns the first configuration file found in the XDG
+// configuration directories, $XDG_CONFIG_HOME (or ~/.config) and then each of
+// $XDG_CONFIG_DIRS (or /etc/xdg), or an empty string when there is none.
+func DefaultPath() string {
+	var dirs []string
+	if home := os.Getenv("XDG_CONFIG_HOME"); home != "" {
+		dirs = append(dirs, home)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config"))
+	}
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+	dirs = append(dirs, filepath.SplitList(configDirs)...)
+
+	for _, dir := range dirs {
+		// relative paths are invalid and ignored, as the spec says
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Load reads the configuration file at the given path. Errors name the file.
+func Load(path string) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config, err := Parse(file)
+	if err != nil {
+		var configErr *Error
+		if errors.As(err, &configErr) {
+			configErr.Path = path
+			return nil, configErr
+		}
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	config.Path = path
+	return config, nil
+}
+
+// Parse reads a configuration file, rejecting rules with settings it doesn't
+// know about so typos don't go unnoticed. Mistakes are reported as an *Error
+// with the line they're on.
+func Parse(reader io.Reader) (*File, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if token, err := decoder.Token(); err != nil {
+		return nil, syntaxError(content, err)
+	} else if token != json.Delim('{') {
+		return nil, &Error{Line: lineAt(content, 0), Err: fmt.Errorf("expected a JSON object")}
+	}
+
+	config := &File{}
+	for decoder.More() {
+		line := lineAt(content, decoder.InputOffset())
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, syntaxError(content, err)
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, syntaxError(content, err)
+		}
+		start := decoder.InputOffset() - int64(len(value))
+		if err := config.set(token.(string), value, content, start); err != nil {
+			var configErr *Error
+			if errors.As(err, &configErr) {
+				return nil, err
+			}
+			return nil, &Error{Line: line, Err: err}
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, syntaxError(content, err)
+	}
+	return config, nil
+}
+
+// Decode the icons setting, lowercasing its keys and refusing keys that only
+// differ in case since either could end up picked.
+func decodeIcons(value json.RawMessage) (map[string]string, error) {
+	var icons map[string]string
+	if err := json.Unmarshal(value, &icons); err != nil {
+		return nil, err
+	}
+	if icons == nil {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(icons))
+	for key := range icons {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lowered := make(map[string]string, len(icons))
+	seen := map[string]string{}
+	for _, key := range keys {
+		lower := strings.ToLower(key)
+		if other, found := seen[lower]; found {
+			return nil, fmt.Errorf("%q and %q only differ in case", other, key)
+		}
+		seen[lower] = key
+		lowered[lower] = icons[key]
+	}
+	return lowered, nil
+}
+
+// Set the named setting of the file to the given JSON value, which starts at
+// the given offset of the file's content.
+func (file *File) set(name string, value json.RawMessage, content []byte, start int64) error {
+	var err error
+	switch name {
+	case "icons":
+		file.Icons, err = decodeIcons(value)
+	case "default_icon":
+		err = json.Unmarshal(value, &file.DefaultIcon)
+	case "mask_text":
+		err = json.Unmarshal(value, &file.MaskText)
+	case "rewrite":
+		file.Rewrite, err = decodeRules("rewrite", value, content, start, func(i int, rule *RewriteRule) error {
+			if rule.Match == nil {
+				return fmt.Errorf("rewrite rule %d has no match", i+1)
+			}
+			return nil
+		})
+	case "colors":
+		file.Colors, err = decodeRules("color", value, content, start, func(i int, rule *ColorRule) error {
+			return nil
+		})
+	case "masks":
+		file.Masks, err = decodeRules("mask", value, content, start, func(i int, rule *MaskRule) error {
+			if rule.Class == "" && rule.State == "" && rule.Title == nil && !rule.Incognito {
+				return fmt.Errorf("mask rule %d has no class, state, title or incognito to match", i+1)
+			}
+			return nil
+		})
+	default:
+		setting, ok := settingValue(value)
+		if !ok {
+			return fmt.Errorf("setting %q must be a string, number, boolean or list of strings", name)
+		}
+		if file.Settings == nil {
+			file.Settings = map[string]Setting{}
+		}
+		file.Settings[name] = Setting{Value: setting, Line: lineAt(content, start)}
+	}
+	if err != nil {
+		var configErr *Error
+		if !errors.As(err, &configErr) {
+			err = fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return err
+}
+
+// Decode a JSON list of the given kind of rules, rejecting unknown fields and
+// checking each rule with the given function. Mistakes are reported with the
+// line of the rule.
+func decodeRules[T any](kind string, value json.RawMessage, content []byte, start int64, check func(int, *T) error) ([]T, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("expected a list of %s rules", kind)
+	}
+
+	var rules []T
+	for decoder.More() {
+		line := lineAt(content, start+decoder.InputOffset())
+		var rule T
+		err := decoder.Decode(&rule)
+		if err == nil {
+			err = check(len(rules), &rule)
+		} else {
+			err = fmt.Errorf("%s rule %d: %w", kind, len(rules)+1, err)
+		}
+		if err != nil {
+			return nil, &Error{Line: line, Err: err}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Convert a JSON setting value into the text it would be given as on the
+// command line.
+func settingValue(value json.RawMessage) (string, bool) {
+	var text string
+	if json.Unmarshal(value, &text) == nil {
+		return text, true
+	}
+	var flag bool
+	if json.Unmarshal(value, &flag) == nil {
+		return strconv.FormatBool(flag), true
+	}
+	var number json.Number
+	if json.Unmarshal(value, &number) == nil {
+		return number.String(), true
+	}
+	var list []string
+	if json.Unmarshal(value, &list) == nil {
+		return strings.Join(list, ","), true
+	}
+	return "", false
+}
+
+// Report a syntax error from decoding the given content with its line.
+func syntaxError(content []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &Error{Line: lineAt(content, syntaxErr.Offset), Err: err}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &Error{Line: lineAt(content, int64(len(content))), Err: fmt.Errorf("unexpected end of JSON input")}
+	}
+	return err
+}
+
+// Return the line number of the first value at or after the given offset of
+// the content, skipping the whitespace and separators in between.
+func lineAt(content []byte, offset int64) int {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	for offset < int64(len(content)) && strings.IndexByte(" \t\r\n,:", content[offset]) >= 0 {
+		offset++
+	}
+	return 1 + bytes.Count(content[:offset], []byte("\n"))
+}
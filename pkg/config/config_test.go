@@ -0,0 +1,215 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseIcons(t *testing.T) {
+	config, err := Parse(strings.NewReader(`{
+		"icons": {"firefox": "B", "Alacritty": "T"},
+		"default_icon": "W"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Icons) != 2 || config.Icons["firefox"] != "B" || config.Icons["alacritty"] != "T" || config.DefaultIcon != "W" {
+		t.Fatalf("Unexpected config %+v", config)
+	}
+
+	_, err = Parse(strings.NewReader(`{"icons": {"firefox": "B", "Firefox": "F"}}`))
+	if err == nil || !strings.Contains(err.Error(), `"Firefox" and "firefox" only differ in case`) {
+		t.Fatalf("Expected keys differing in case to be refused, got %v", err)
+	}
+}
+
+func TestParseRewrite(t *testing.T) {
+	config, err := Parse(strings.NewReader(`{
+		"rewrite": [
+			{"class": "firefox", "match": " — Mozilla Firefox$"},
+			{"match": "^(.*) - (.*) - Visual Studio Code$", "replace": "$2: $1"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrites := config.Rewrites()
+	if len(rewrites) != 2 || rewrites[0].Class != "firefox" || rewrites[1].Replacement != "$2: $1" {
+		t.Fatalf("Unexpected rewrites %+v", rewrites)
+	}
+	if rewrites[1].Pattern.String() != "^(.*) - (.*) - Visual Studio Code$" {
+		t.Fatalf("Unexpected pattern %s", rewrites[1].Pattern)
+	}
+}
+
+func TestParseBadRewrite(t *testing.T) {
+	configs := map[string]string{
+		`{"rewrite": [{"match": "(potato"}]}`: "missing closing )",
+		`{"rewrite": [{"class": "firefox"}]}`: "rewrite rule 1 has no match",
+		`{"rewrite": [{"match": 42}]}`:        "cannot unmarshal number",
+	}
+	for content, expected := range configs {
+		_, err := Parse(strings.NewReader(content))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected %q for %s, got %v", expected, content, err)
+		}
+	}
+}
+
+func TestParseColors(t *testing.T) {
+	config, err := Parse(strings.NewReader(`{
+		"colors": [
+			{"class": "Alacritty", "title": "^ssh prod-", "color": "#FFFFFF", "background": "#CC0000"},
+			{"instance": "scratch", "border": "#888888"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors := config.ColorRules()
+	if len(colors) != 2 || colors[0].Title.String() != "^ssh prod-" || colors[0].Background != "#CC0000" {
+		t.Fatalf("Unexpected colors %+v", colors)
+	}
+	if colors[1].Title != nil || colors[1].Instance != "scratch" || colors[1].Border != "#888888" {
+		t.Fatalf("Unexpected colors %+v", colors)
+	}
+}
+
+func TestParseMasks(t *testing.T) {
+	config, err := Parse(strings.NewReader(`{
+		"mask_text": "***",
+		"masks": [
+			{"class": "KeePassXC", "text": "passwords"},
+			{"class": "Slack", "title": "^DM "},
+			{"state": "hidden"},
+			{"incognito": true}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	masks := config.MaskRules()
+	if len(masks) != 4 || config.MaskText != "***" || masks[0].Text != "passwords" || masks[1].Title.String() != "^DM " {
+		t.Fatalf("Unexpected masks %+v", masks)
+	}
+	if masks[2].State != "hidden" || !masks[3].Incognito || masks[3].Title != nil {
+		t.Fatalf("Unexpected masks %+v", masks)
+	}
+}
+
+func TestParseMaskWithoutConditions(t *testing.T) {
+	_, err := Parse(strings.NewReader(`{"masks": [{"class": "Slack"}, {"text": "everything"}]}`))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 1: mask rule 2 has no") {
+		t.Fatalf("Expected missing condition error, got %v", err)
+	}
+}
+
+func TestParseUnknownSetting(t *testing.T) {
+	_, err := Parse(strings.NewReader(`{"icon": {"firefox": "B"}}`))
+	if err == nil || !strings.Contains(err.Error(), `"icon"`) {
+		t.Fatalf("Expected unknown field error, got %v", err)
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	config, err := Parse(strings.NewReader(`{
+		"color": "#00EE00",
+		"fixed_width": 64,
+		"append-end": true,
+		"ignore_class": ["rofi", "dmenu"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Setting{
+		"color":        {Value: "#00EE00", Line: 2},
+		"fixed_width":  {Value: "64", Line: 3},
+		"append-end":   {Value: "true", Line: 4},
+		"ignore_class": {Value: "rofi,dmenu", Line: 5},
+	}
+	if len(config.Settings) != len(expected) {
+		t.Fatalf("Unexpected settings %+v", config.Settings)
+	}
+	for name, setting := range expected {
+		if config.Settings[name] != setting {
+			t.Fatalf("Expected %+v for %s, got %+v", setting, name, config.Settings[name])
+		}
+	}
+}
+
+func TestParseErrorLines(t *testing.T) {
+	configs := map[string]string{
+		"{\n\"color\": \"#FFF\"\n\"format\": \"{title}\"\n}":              "line 3: invalid character",
+		"{\n\"color\": \"#FFF\",\n\"icons\": [\"B\"]\n}":                  "line 3: icons: json: cannot unmarshal array",
+		"{\"rewrite\": [\n{\"match\": \"a\"},\n\n{\"match\": \"(b\"}\n]}": "line 4: rewrite rule 2: error parsing regexp",
+		"{\"colors\": [\n{\"class\": \"a\"},\n{\"clas\": \"b\"}\n]}":      "line 3: color rule 2: json: unknown field",
+		"{\n\"masks\": [{\"class\": \"a\"},\n{}]}":                        "line 3: mask rule 2 has no",
+		"{\n\"format\": {\"title\": true}\n}":                             `line 2: setting "format" must be`,
+		"{\n\"color\": \"#FFF\",\n":                                       "line 3: unexpected end of JSON input",
+		"[]":                                                              "line 1: expected a JSON object",
+	}
+	for content, expected := range configs {
+		_, err := Parse(strings.NewReader(content))
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Fatalf("Expected %q for %q, got %v", expected, content, err)
+		}
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	home := t.TempDir()
+	dirs := []string{t.TempDir(), t.TempDir()}
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("XDG_CONFIG_DIRS", strings.Join(dirs, string(os.PathListSeparator)))
+	if path := DefaultPath(); path != "" {
+		t.Fatalf("Expected no configuration file, got %s", path)
+	}
+
+	write := func(dir string) string {
+		path := filepath.Join(dir, FileName)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	if expected := write(dirs[1]); DefaultPath() != expected {
+		t.Fatalf("Expected %s from XDG_CONFIG_DIRS, got %s", expected, DefaultPath())
+	}
+	if expected := write(home); DefaultPath() != expected {
+		t.Fatalf("Expected %s from XDG_CONFIG_HOME, got %s", expected, DefaultPath())
+	}
+}
+
+func TestLoadNamesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"icons": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	if err == nil || !strings.HasPrefix(err.Error(), "config file "+path+": line 1: ") {
+		t.Fatalf("Expected error naming the file, got %v", err)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("Expected missing file error")
+	}
+}
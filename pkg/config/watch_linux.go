@@ -0,0 +1,79 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package config
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Watch calls changed every time the file at the given path is written or
+// replaced, until watching fails. It follows symbolic links to the file, and
+// watches its directory so editors that save by renaming a new file over the
+// old one are noticed too.
+func Watch(path string, changed func()) error {
+	return watch(path, func() {}, changed)
+}
+
+// Watch the file at the given path, calling ready once changes are noticed.
+func watch(path string, ready func(), changed func()) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	dir, name := filepath.Split(filepath.Clean(path))
+	if dir == "" {
+		dir = "."
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		return err
+	}
+	ready()
+
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(fd, buffer)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// each event is followed by the NUL padded name of the file in the
+		// directory it's about
+		matched := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			nameLen := int(binary.NativeEndian.Uint32(buffer[offset+12:]))
+			start := offset + syscall.SizeofInotifyEvent
+			eventName, _, _ := strings.Cut(string(buffer[start:start+nameLen]), "\x00")
+			matched = matched || eventName == name
+			offset = start + nameLen
+		}
+		if matched {
+			changed()
+		}
+	}
+}
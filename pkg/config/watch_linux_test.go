@@ -0,0 +1,82 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "linked.json")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+
+	ready := make(chan struct{})
+	changes := make(chan struct{}, 10)
+	failed := make(chan error, 1)
+	go func() {
+		failed <- watch(link, func() { close(ready) }, func() { changes <- struct{}{} })
+	}()
+	expectChange := func(description string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case err := <-failed:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected a change after %s", description)
+		}
+	}
+	// wait for the watch to start before changing anything
+	select {
+	case <-ready:
+	case err := <-failed:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the watch to start")
+	}
+
+	// other files in the same directory are left alone
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"color": "#FFFFFF"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectChange("writing the file")
+	select {
+	case <-changes:
+		t.Fatal("Expected no change from writing another file")
+	default:
+	}
+
+	replacement := filepath.Join(dir, "config.json.new")
+	if err := os.WriteFile(replacement, []byte(`{"color": "#000000"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, path); err != nil {
+		t.Fatal(err)
+	}
+	expectChange("renaming over the file")
+}
@@ -0,0 +1,25 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package config
+
+import "errors"
+
+// Watch needs inotify, which is only there on Linux. Elsewhere it fails right
+// away, leaving SIGHUP as the way to reload.
+func Watch(path string, changed func()) error {
+	return errors.New("watching the configuration file is only supported on Linux")
+}
@@ -0,0 +1,281 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package control is a small line-delimited JSON protocol over a unix socket,
+// for scripting a running instance from keybindings. Each request is one JSON
+// object on a line, answered by one JSON object on a line.
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Commands understood by a running instance.
+const (
+	// Window gets the active window of each title source.
+	Window = "window"
+
+	// Override shows the request's Text instead of every title, for Seconds
+	// or until it's cleared with an empty Text.
+	Override = "override"
+
+	// Privacy toggles privacy mode, or switches it to the request's Private
+	// when that's set.
+	Privacy = "privacy"
+
+	// Color changes the text color of the titles until the next reload.
+	Color = "color"
+
+	// Refresh forces i3status to print a new status line.
+	Refresh = "refresh"
+
+	// Stats dumps counters of what the running instance did.
+	Stats = "stats"
+)
+
+// Commands lists every command, in the order they're documented.
+var Commands = []string{Window, Override, Privacy, Color, Refresh, Stats}
+
+// SocketName is the name of the socket in $XDG_RUNTIME_DIR.
+const SocketName = "i3status-title-on-bar.sock"
+
+// ErrInUse is returned when another running instance answers on the socket.
+var ErrInUse = errors.New("control socket is in use by another instance")
+
+// DefaultIdleTimeout is how long a connection may usually sit without sending a
+// request before it's closed.
+const DefaultIdleTimeout = 30 * time.Second
+
+// Request is a command sent to a running instance.
+type Request struct {
+	Command string  `json:"command"`
+	Text    string  `json:"text,omitempty"`
+	Seconds float64 `json:"seconds,omitempty"`
+	Private *bool   `json:"private,omitempty"`
+	Color   string  `json:"color,omitempty"`
+}
+
+// Response answers a Request, with Error set when it failed.
+type Response struct {
+	OK      bool         `json:"ok"`
+	Error   string       `json:"error,omitempty"`
+	Windows []WindowInfo `json:"windows,omitempty"`
+	Private *bool        `json:"private,omitempty"`
+	Stats   *StatsInfo   `json:"stats,omitempty"`
+}
+
+// WindowInfo describes the active window of a title source, named by its
+// instance when there's more than one.
+type WindowInfo struct {
+	Source    string   `json:"source,omitempty"`
+	ID        uint64   `json:"id,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	Class     string   `json:"class,omitempty"`
+	Instance  string   `json:"instance,omitempty"`
+	Role      string   `json:"role,omitempty"`
+	Workspace string   `json:"workspace,omitempty"`
+	PID       int      `json:"pid,omitempty"`
+	Floating  bool     `json:"floating,omitempty"`
+	Urgent    bool     `json:"urgent,omitempty"`
+	States    []string `json:"states,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// StatsInfo counts what a running instance did since it started, along with
+// what it's currently showing.
+type StatsInfo struct {
+	Started       time.Time `json:"started"`
+	StatusLines   int64     `json:"status_lines"`
+	WindowChanges int64     `json:"window_changes"`
+	Refreshes     int64     `json:"refreshes"`
+	Reloads       int64     `json:"reloads"`
+	Requests      int64     `json:"requests"`
+	Color         string    `json:"color"`
+	Private       bool      `json:"private"`
+	Override      string    `json:"override,omitempty"`
+}
+
+// Handler answers a Request.
+type Handler func(request Request) Response
+
+// Failed returns the Response to a Request that failed with the given error.
+func Failed(err error) Response {
+	return Response{Error: err.Error()}
+}
+
+// DefaultPath returns the socket path in $XDG_RUNTIME_DIR, falling back to a
+// directory of the user's own in the temporary directory without it, which
+// Listen creates.
+func DefaultPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, SocketName)
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("i3status-title-on-bar-%d", os.Getuid()), SocketName)
+}
+
+// Listen on the socket at the given path, only accessible by the current user.
+// A missing directory for it is created only accessible by the current user,
+// and a directory that other users could swap the socket in is refused. A
+// socket left behind by an instance that's gone is replaced, while one that
+// still answers fails with ErrInUse.
+func Listen(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+	if err := checkDir(dir); err != nil {
+		return nil, err
+	}
+
+	listener, err := listenPrivate(path)
+	if errors.Is(err, syscall.EADDRINUSE) {
+		if conn, dialErr := net.Dial("unix", path); dialErr == nil {
+			conn.Close()
+			return nil, ErrInUse
+		}
+		if err := checkOwned(path, os.ModeSocket); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+		listener, err = listenPrivate(path)
+	}
+	return listener, err
+}
+
+// Listen on the given path with a umask that leaves the socket only accessible
+// by the current user from the moment it's created, rather than changing its
+// mode afterwards when others may have connected already.
+func listenPrivate(path string) (net.Listener, error) {
+	umask := syscall.Umask(0177)
+	defer syscall.Umask(umask)
+	return net.Listen("unix", path)
+}
+
+// Make sure no other user can replace what's in the given directory, which
+// has to be the current user's own, or root's without others being able to
+// write to it, like /run, or only able to remove their own files, like /tmp.
+func checkDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	owner := info.Sys().(*syscall.Stat_t).Uid
+	if int(owner) == os.Getuid() {
+		return nil
+	}
+	if owner != 0 {
+		return fmt.Errorf("%s is owned by another user", dir)
+	}
+	if info.Mode().Perm()&0022 != 0 && info.Mode()&os.ModeSticky == 0 {
+		return fmt.Errorf("%s is writable by other users", dir)
+	}
+	return nil
+}
+
+// Make sure the file at the given path is of the given type and the current
+// user's own.
+func checkOwned(path string, fileType os.FileMode) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fileType || int(info.Sys().(*syscall.Stat_t).Uid) != os.Getuid() {
+		return fmt.Errorf("%s is not a socket of the current user", path)
+	}
+	return nil
+}
+
+// Serve answers the requests on every connection to the given listener with
+// the given handler until the listener is closed, closing connections that sit
+// idle for longer than the given timeout. Anything that goes wrong, including
+// closing the listener, is reported to onError.
+func Serve(listener net.Listener, handler Handler, idleTimeout time.Duration, onError func(error)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			onError(err)
+			return
+		}
+		go func() {
+			defer conn.Close()
+			if err := serveConn(conn, handler, idleTimeout); err != nil {
+				onError(err)
+			}
+		}()
+	}
+}
+
+// Answer each line of the given connection until it's closed, or it sits idle
+// for longer than the given timeout.
+func serveConn(conn net.Conn, handler Handler, idleTimeout time.Duration) error {
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		var request Request
+		var response Response
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			response = Failed(fmt.Errorf("bad request: %w", err))
+		} else {
+			response = handler(request)
+		}
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+	if errors.Is(scanner.Err(), os.ErrDeadlineExceeded) {
+		return nil
+	}
+	return scanner.Err()
+}
+
+// Send the given request to the instance listening on the socket at the given
+// path and return its response. When the response has an error, it's
+// returned as an error too.
+func Send(path string, request Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return Response{}, err
+	}
+	var response Response
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return Response{}, err
+	}
+	if !response.OK {
+		return response, errors.New(response.Error)
+	}
+	return response, nil
+}
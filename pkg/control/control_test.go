@@ -0,0 +1,176 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func echoHandler(request Request) Response {
+	if request.Command != Window {
+		return Failed(fmt.Errorf("unknown command %q", request.Command))
+	}
+	return Response{OK: true, Windows: []WindowInfo{{Title: request.Text}}}
+}
+
+func serve(t *testing.T, handler Handler, idleTimeout time.Duration) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), SocketName)
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go Serve(listener, handler, idleTimeout, func(error) {})
+	return path
+}
+
+func TestSend(t *testing.T) {
+	path := serve(t, echoHandler, DefaultIdleTimeout)
+	response, err := Send(path, Request{Command: Window, Text: "vim"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.OK || len(response.Windows) != 1 || response.Windows[0].Title != "vim" {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+
+	_, err = Send(path, Request{Command: "potato"})
+	if err == nil || err.Error() != `unknown command "potato"` {
+		t.Fatalf("Expected the error of the response, got %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected a socket only the user can use, got %v", info.Mode())
+	}
+}
+
+func TestServeLines(t *testing.T) {
+	path := serve(t, echoHandler, DefaultIdleTimeout)
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintln(conn, `{"command": "window", "text": "one"}`)
+	fmt.Fprintln(conn, `not json`)
+	fmt.Fprintln(conn, `{"command": "window", "text": "two"}`)
+	responses := bufio.NewScanner(conn)
+	expected := []string{
+		`{"ok":true,"windows":[{"title":"one"}]}`,
+		`{"ok":false,"error":"bad request: invalid character 'o' in literal null (expecting 'u')"}`,
+		`{"ok":true,"windows":[{"title":"two"}]}`,
+	}
+	for _, line := range expected {
+		if !responses.Scan() {
+			t.Fatal("Expected a response")
+		}
+		if responses.Text() != line {
+			t.Fatalf("Expected %s, got %s", line, responses.Text())
+		}
+	}
+}
+
+func TestListenInUse(t *testing.T) {
+	path := serve(t, echoHandler, DefaultIdleTimeout)
+	if _, err := Listen(path); !errors.Is(err, ErrInUse) {
+		t.Fatalf("Expected ErrInUse, got %v", err)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SocketName)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// leave the socket file behind, like an instance that was killed
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	listener, err = Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+}
+
+func TestListenRefusesOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SocketName)
+	if err := os.WriteFile(path, []byte("keep me"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(path); err == nil {
+		t.Fatal("Expected a file that isn't a socket to be refused")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected the file to be left alone, got %v", err)
+	}
+}
+
+func TestListenCreatesPrivateDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "i3status-title-on-bar-1000")
+	listener, err := Listen(filepath.Join(dir, SocketName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Fatalf("Expected a directory only the user can use, got %v", info.Mode())
+	}
+}
+
+func TestServeClosesIdleConnections(t *testing.T) {
+	path := serve(t, echoHandler, 50*time.Millisecond)
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected the idle connection to be closed, got %v", err)
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if path := DefaultPath(); path != "/run/user/1000/"+SocketName {
+		t.Fatalf("Unexpected path %s", path)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	expected := filepath.Join(os.TempDir(), fmt.Sprintf("i3status-title-on-bar-%d", os.Getuid()), SocketName)
+	if path := DefaultPath(); path != expected {
+		t.Fatalf("Expected %s, got %s", expected, path)
+	}
+}
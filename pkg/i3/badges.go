@@ -0,0 +1,103 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"strings"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// Badges are the names of the window states that can be shown, in the order
+// they're shown in.
+var Badges = []string{"fullscreen", "maximized", "sticky", "hidden", "above", "floating"}
+
+// DefaultBadgeGlyphs are shown for each badge unless Options.BadgeGlyphs has
+// another one.
+var DefaultBadgeGlyphs = map[string]string{
+	"fullscreen": "⛶",
+	"maximized":  "□",
+	"sticky":     "∗",
+	"hidden":     "–",
+	"above":      "↑",
+	"floating":   "◇",
+}
+
+// Report whether the named badge applies to the given window.
+func hasBadge(info window.Info, badge string) bool {
+	switch badge {
+	case "floating":
+		return info.Floating
+	case "maximized":
+		// EWMH maximizes in each direction separately
+		return hasState(info, "maximized_vert") && hasState(info, "maximized_horz")
+	default:
+		return hasState(info, badge)
+	}
+}
+
+func hasState(info window.Info, state string) bool {
+	for _, s := range info.States {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the glyph shown for the named badge.
+func badgeGlyph(badge string, options Options) string {
+	if glyph, found := options.BadgeGlyphs[badge]; found {
+		return glyph
+	}
+	return DefaultBadgeGlyphs[badge]
+}
+
+// Add a field for each badge holding its glyph when it applies to the given
+// window, and {badges} with all of those that apply.
+func addBadgeFields(fields map[string]string, info window.Info, options Options) {
+	var all strings.Builder
+	for _, badge := range Badges {
+		fields[badge] = ""
+		if hasBadge(info, badge) {
+			fields[badge] = badgeGlyph(badge, options)
+			all.WriteString(fields[badge])
+		}
+	}
+	fields["badges"] = all.String()
+}
+
+func newBadgeNode(color string, badge string, glyph string, instance string) map[string]interface{} {
+	node := map[string]interface{}{
+		"name":      "badge_" + badge,
+		"full_text": glyph,
+		"color":     color}
+	if instance != "" {
+		node["instance"] = instance
+	}
+	return node
+}
+
+// Build a node for each of the badges in Options.BadgeBlocks that applies to
+// the given window.
+func badgeNodes(info window.Info, options Options, instance string) []interface{} {
+	var nodes []interface{}
+	for _, badge := range options.BadgeBlocks {
+		if hasBadge(info, badge) {
+			nodes = append(nodes, newBadgeNode(options.Color, badge, badgeGlyph(badge, options), instance))
+		}
+	}
+	return nodes
+}
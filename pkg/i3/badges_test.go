@@ -0,0 +1,79 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+func TestAddBadgeFields(t *testing.T) {
+	info := window.Info{Title: "movie.mkv", States: []string{"fullscreen", "maximized_vert", "above"}, Floating: true}
+	fields := templateFields(info, "")
+	addBadgeFields(fields, info, Options{BadgeGlyphs: map[string]string{"above": "^"}})
+
+	expected := map[string]string{
+		"fullscreen": "⛶",
+		"maximized":  "",
+		"sticky":     "",
+		"hidden":     "",
+		"above":      "^",
+		"floating":   "◇",
+		"badges":     "⛶^◇",
+	}
+	for field, value := range expected {
+		if fields[field] != value {
+			t.Fatalf("Expected %q for {%s}, got %q", value, field, fields[field])
+		}
+	}
+}
+
+func TestHasBadgeMaximized(t *testing.T) {
+	if !hasBadge(window.Info{States: []string{"maximized_horz", "maximized_vert"}}, "maximized") {
+		t.Fatal("Expected a window maximized both ways to be maximized")
+	}
+	if hasBadge(window.Info{States: []string{"maximized_horz"}}, "maximized") {
+		t.Fatal("Expected a window maximized one way not to be maximized")
+	}
+}
+
+func TestRunBadgeBlocks(t *testing.T) {
+	script := "focus 1 mpv movie.mkv\nstate 1 fullscreen sticky"
+	windowAPI, err := window.NewFake(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	sources := []TitleSource{{API: windowAPI}}
+	options := Options{Color: "#00FF00", Format: "{title} {badges}", BadgeBlocks: []string{"above", "sticky"}}
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	output := stdout.String()
+	expected := `[{"color":"#00FF00","full_text":"movie.mkv ⛶∗","name":"window_title"},` +
+		`{"color":"#00FF00","full_text":"∗","name":"badge_sticky"},{"color"`
+	if !strings.Contains(output, expected) {
+		t.Fatalf("Expected badges in the title and a sticky node, got %s", output)
+	}
+}
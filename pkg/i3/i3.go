@@ -17,11 +17,14 @@ package i3
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/rholder/i3status-title-on-bar/pkg/rules"
 	"github.com/rholder/i3status-title-on-bar/pkg/window"
 )
 
@@ -42,21 +45,307 @@ func scannerError(out io.Writer, scanner *bufio.Scanner, errorCode int) int {
 	return errorCode
 }
 
-func newTitleNode(color string, title string) map[string]string {
-	return map[string]string{
+// Options controls how window title nodes are added to the i3status output.
+type Options struct {
+	// Color is the text color of each title node.
+	Color string
+
+	// AppendEnd adds the title nodes to the end instead of the beginning.
+	AppendEnd bool
+
+	// FixedWidth truncates and pads titles to this width when positive.
+	FixedWidth int
+
+	// NoWindowText is shown in place of a title when no window is active.
+	NoWindowText string
+
+	// NoWindowColor is the text color used with NoWindowText, Color when
+	// empty.
+	NoWindowColor string
+
+	// OmitNoWindow leaves the node out entirely when no window is active.
+	OmitNoWindow bool
+
+	// ErrorText is shown in place of a title when the window backend fails.
+	ErrorText string
+
+	// ErrorColor is the text color used with ErrorText, Color when empty.
+	ErrorColor string
+
+	// Format is the template for the title text, DefaultFormat when empty.
+	// Fields like {title} and {workspace} are replaced with their values.
+	Format string
+
+	// WorkspaceBlock adds a separate node with the current workspace name in
+	// front of each title node.
+	WorkspaceBlock bool
+
+	// Rewrites are applied to the title before it's used in the Format.
+	Rewrites rules.Rewrites
+
+	// Colors style the title node of the windows they match, overriding
+	// Color. The first matching rule wins.
+	Colors rules.Colors
+
+	// Masks hide the titles of the windows they match behind MaskText, or
+	// the text of the matching Mask.
+	Masks rules.Masks
+
+	// MaskText replaces masked titles, rules.DefaultMaskText when empty.
+	MaskText string
+
+	// Private hides every title behind MaskText while it's true. It can be
+	// flipped at any time, like from a signal handler, and is never private
+	// when nil.
+	Private *atomic.Bool
+
+	// Override replaces every title with its text until it expires, like
+	// Private it can be set at any time and is never there when nil.
+	Override *atomic.Pointer[Override]
+
+	// Icons maps window classes, or instances, to a glyph put in front of
+	// the title, or wherever {icon} is in the Format. Keys must be
+	// lowercase, the class and instance are lowercased to look them up.
+	Icons map[string]string
+
+	// DefaultIcon is used for windows without an entry in Icons.
+	DefaultIcon string
+
+	// BadgeGlyphs replace the DefaultBadgeGlyphs shown for window states like
+	// fullscreen, both in the {fullscreen} style fields and in badge nodes.
+	BadgeGlyphs map[string]string
+
+	// BadgeBlocks are the Badges that get a separate node after each title
+	// node while they apply to the active window.
+	BadgeBlocks []string
+
+	// UrgentBlock adds a separate urgent node listing the titles of all
+	// windows demanding attention after each title node, left out when there
+	// are none.
+	UrgentBlock bool
+
+	// TerminalClasses are the window classes whose foreground process fills
+	// in the {command} and {cwd} fields, DefaultTerminalClasses when empty.
+	TerminalClasses []string
+
+	// TerminalTimeout caps the time spent looking for the foreground process
+	// of a terminal, DefaultTerminalTimeout when not positive.
+	TerminalTimeout time.Duration
+}
+
+// UrgentSeparator goes between the titles listed in the urgent node.
+const UrgentSeparator = " | "
+
+// TitleSource is a window.API feeding its own title node. With more than one
+// source, the Instance tells the nodes apart.
+type TitleSource struct {
+	// Instance is set as the instance of the node when not empty.
+	Instance string
+
+	// API is where the title comes from.
+	API window.API
+}
+
+func newTitleNode(color string, title string, instance string) map[string]interface{} {
+	node := map[string]interface{}{
 		"name":      "window_title",
 		"full_text": title,
 		"color":     color}
+	if instance != "" {
+		node["instance"] = instance
+	}
+	return node
 }
 
+func newWorkspaceNode(color string, workspace string, instance string) map[string]interface{} {
+	node := map[string]interface{}{
+		"name":      "workspace",
+		"full_text": workspace,
+		"color":     color}
+	if instance != "" {
+		node["instance"] = instance
+	}
+	return node
+}
+
+func newUrgentNode(color string, titles []string, instance string) map[string]interface{} {
+	node := map[string]interface{}{
+		"name":      "urgent_windows",
+		"full_text": strings.Join(titles, UrgentSeparator),
+		"color":     color,
+		"urgent":    true}
+	if instance != "" {
+		node["instance"] = instance
+	}
+	return node
+}
+
+// Truncate the given value to fixedWidth columns, padding it with spaces when it
+// is shorter. Wide characters, like some icons, count as two columns.
 func truncateAndPad(value string, fixedWidth int) string {
-	safeSubstring := value
-	if len(value) > fixedWidth {
-		safeSubstring = value[0:fixedWidth]
+	var truncated strings.Builder
+	width := 0
+	for _, r := range value {
+		if width+runeWidth(r) > fixedWidth {
+			break
+		}
+		truncated.WriteRune(r)
+		width += runeWidth(r)
+	}
+	return truncated.String() + strings.Repeat(" ", fixedWidth-width)
+}
+
+// Build the nodes for the given source, the title node with the workspace node
+// in front of it and the badge and urgent nodes after it when enabled.
+func sourceNodes(source TitleSource, options Options) []interface{} {
+	format := firstNonEmpty(options.Format, DefaultFormat)
+
+	// only ask for the workspace when it's going to be shown
+	workspace := ""
+	if options.WorkspaceBlock || templateUses(format, "workspace") {
+		workspace, _ = window.CurrentWorkspace(source.API)
+	}
+
+	var nodes []interface{}
+	if options.WorkspaceBlock && workspace != "" {
+		nodes = append(nodes, newWorkspaceNode(options.Color, workspace, source.Instance))
+	}
+	info, err := source.API.ActiveWindow()
+	if titleNode := sourceTitleNode(source, options, format, workspace, info, err); titleNode != nil {
+		nodes = append(nodes, titleNode)
+	}
+	if err == nil {
+		nodes = append(nodes, badgeNodes(info, options, source.Instance)...)
+	}
+	if options.UrgentBlock {
+		if urgentNode := sourceUrgentNode(source, options); urgentNode != nil {
+			nodes = append(nodes, urgentNode)
+		}
+	}
+	return nodes
+}
+
+// Build the urgent node for the given source, or nil when no window demands
+// attention or the backend can't tell.
+func sourceUrgentNode(source TitleSource, options Options) map[string]interface{} {
+	urgent, err := window.UrgentWindows(source.API)
+	if err != nil || len(urgent) == 0 {
+		return nil
 	}
+	var titles []string
+	for _, info := range urgent {
+		if text, masked := MaskedTitle(info, options); masked {
+			titles = append(titles, text)
+		} else {
+			titles = append(titles, options.Rewrites.Apply(info))
+		}
+	}
+	return newUrgentNode(options.Color, titles, source.Instance)
+}
+
+// Build the title node for the given source from its active window, falling
+// back to the configured placeholders when there is no active window or the
+// backend failed. Returns nil when the node should be left out.
+func sourceTitleNode(source TitleSource, options Options, format string, workspace string,
+	info window.Info, err error) map[string]interface{} {
+
+	original := info
+	info.Title = options.Rewrites.Apply(info)
+	fields := templateFields(info, workspace)
+	addBadgeFields(fields, info, options)
+	fields["icon"] = windowIcon(info, options)
+	if templateUses(format, "command") || templateUses(format, "cwd") {
+		addTerminalFields(fields, info, options)
+	}
+	title, color := expandTemplate(format, fields), options.Color
+	if fields["icon"] != "" && !templateUses(format, "icon") {
+		title = fields["icon"] + " " + title
+	}
+	override, overridden := currentOverride(options, time.Now())
+	masked := false
+	if overridden {
+		title, color = override, options.Color
+	} else if errors.Is(err, window.ErrNoActiveWindow) {
+		if options.OmitNoWindow {
+			return nil
+		}
+		title, color = options.NoWindowText, firstNonEmpty(options.NoWindowColor, options.Color)
+	} else if err != nil {
+		title, color = options.ErrorText, firstNonEmpty(options.ErrorColor, options.Color)
+	} else if text, hidden := MaskedTitle(original, options); hidden {
+		title, masked = text, true
+	}
+
+	if options.FixedWidth > 0 {
+		title = truncateAndPad(title, options.FixedWidth)
+	}
+	node := newTitleNode(color, title, source.Instance)
+	if err == nil && !overridden {
+		// a color picked by a hidden title would give away which window it is
+		if !masked {
+			applyColorRule(node, options.Colors, original)
+		}
+		if info.Urgent {
+			node["urgent"] = true
+		}
+	}
+	return node
+}
+
+// Override is text shown instead of every title, until a point in time unless
+// that's zero.
+type Override struct {
+	Text  string
+	Until time.Time
+}
+
+// Get the override text shown at the given time, and whether there is one.
+func currentOverride(options Options, now time.Time) (string, bool) {
+	if options.Override == nil {
+		return "", false
+	}
+	override := options.Override.Load()
+	if override == nil || override.Text == "" || (!override.Until.IsZero() && !now.Before(override.Until)) {
+		return "", false
+	}
+	return override.Text, true
+}
+
+// MaskedTitle returns what to show instead of the title of the given window
+// when it's hidden by privacy mode or one of the masks, and whether it's hidden
+// at all.
+func MaskedTitle(info window.Info, options Options) (string, bool) {
+	if options.Private != nil && options.Private.Load() {
+		return firstNonEmpty(options.MaskText, rules.DefaultMaskText), true
+	}
+	return options.Masks.Text(info, options.MaskText)
+}
 
-	template := "%-" + strconv.Itoa(fixedWidth) + "s"
-	return fmt.Sprintf(template, safeSubstring)
+// Style the given node with the first of the given rules matching the window.
+func applyColorRule(node map[string]interface{}, colors rules.Colors, info window.Info) {
+	found := colors.Find(info)
+	if found < 0 {
+		return
+	}
+	rule := colors[found]
+	if rule.Color != "" {
+		node["color"] = rule.Color
+	}
+	if rule.Background != "" {
+		node["background"] = rule.Background
+	}
+	if rule.Border != "" {
+		node["border"] = rule.Border
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
 }
 
 // RunJSONParsingLoop parses the incoming JSON coming in from an
@@ -65,6 +354,57 @@ func truncateAndPad(value string, fixedWidth int) string {
 func RunJSONParsingLoop(stdin io.Reader, stdout io.Writer, stderr io.Writer, windowAPI window.API,
 	color string, appendEnd bool, fixedWidth int) int {
 
+	sources := []TitleSource{{API: windowAPI}}
+	return Run(stdin, stdout, stderr, sources, Options{Color: color, AppendEnd: appendEnd, FixedWidth: fixedWidth})
+}
+
+// LiveOptions holds the Options of a running RunLive loop, which can be
+// swapped for new ones at any time to take effect from the next status line on.
+type LiveOptions struct {
+	current atomic.Pointer[Options]
+}
+
+// NewLiveOptions creates LiveOptions starting out with the given Options.
+func NewLiveOptions(options Options) *LiveOptions {
+	live := &LiveOptions{}
+	live.Store(options)
+	return live
+}
+
+// Load returns the current Options.
+func (live *LiveOptions) Load() Options {
+	return *live.current.Load()
+}
+
+// Store replaces the current Options with the given ones.
+func (live *LiveOptions) Store(options Options) {
+	live.current.Store(&options)
+}
+
+// Update changes the current Options with the given function, which is called
+// again when other Options were stored in the meantime so no change is lost.
+func (live *LiveOptions) Update(change func(options *Options)) {
+	for {
+		current := live.current.Load()
+		options := *current
+		change(&options)
+		if live.current.CompareAndSwap(current, &options) {
+			return
+		}
+	}
+}
+
+// Run parses the incoming JSON coming in from an i3status-formatted source,
+// adds a window title node for each of the given sources to the JSON as
+// configured by the given Options, and outputs the modified JSON.
+func Run(stdin io.Reader, stdout io.Writer, stderr io.Writer, sources []TitleSource, options Options) int {
+	return RunLive(stdin, stdout, stderr, sources, NewLiveOptions(options))
+}
+
+// RunLive is Run with Options that can change while it's running, using the
+// current ones for each status line.
+func RunLive(stdin io.Reader, stdout io.Writer, stderr io.Writer, sources []TitleSource, live *LiveOptions) int {
+
 	// Read from input using a Scanner.
 	scanner := bufio.NewScanner(stdin)
 
@@ -105,24 +445,23 @@ func RunJSONParsingLoop(stdin io.Reader, stdout io.Writer, stderr io.Writer, win
 			return BadInputJSONErrorCode
 		}
 
-		// inject window title node first
-		title := windowAPI.ActiveWindowTitle()
-		if fixedWidth > 0 {
-			title = truncateAndPad(title, fixedWidth)
+		// inject window title nodes first
+		options := live.Load()
+		var titleNodes []interface{}
+		for _, source := range sources {
+			titleNodes = append(titleNodes, sourceNodes(source, options)...)
 		}
-		titleNode := newTitleNode(color, title)
 
 		// bolt together the JSON
 		var allJSON []interface{}
-		if appendEnd {
+		if options.AppendEnd {
 			allJSON = append(allJSON, parsed...)
-			allJSON = append(allJSON, titleNode)
+			allJSON = append(allJSON, titleNodes...)
 		} else {
-			allJSON = append(allJSON, titleNode)
+			allJSON = append(allJSON, titleNodes...)
 			allJSON = append(allJSON, parsed...)
 		}
 
-		// parsed = append(titleNode, parsed...) // TODO figure out how to do this cleanly
 		parsedJSON, err := json.Marshal(allJSON)
 		if err != nil {
 			fmt.Fprintln(stderr, err)
@@ -15,10 +15,20 @@
 package i3
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/rules"
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
 )
 
 type TestWindowAPI struct{}
@@ -27,10 +37,31 @@ func (testWindowAPI TestWindowAPI) ActiveWindowTitle() string {
 	return "foo"
 }
 
-func (testWindowAPI TestWindowAPI) DetectWindowTitleChanges(onChange func(), onError func(error)) error {
+func (testWindowAPI TestWindowAPI) ActiveWindow() (window.Info, error) {
+	return window.Info{Title: "foo"}, nil
+}
+
+func (testWindowAPI TestWindowAPI) DetectWindowTitleChanges(onChange func(window.Event), onError func(error)) error {
 	return nil
 }
 
+// Fails with err every time, like a backend that lost its connection.
+type errorWindowAPI struct {
+	err error
+}
+
+func (errorWindowAPI errorWindowAPI) ActiveWindowTitle() string {
+	return ""
+}
+
+func (errorWindowAPI errorWindowAPI) ActiveWindow() (window.Info, error) {
+	return window.Info{}, errorWindowAPI.err
+}
+
+func (errorWindowAPI errorWindowAPI) DetectWindowTitleChanges(onChange func(window.Event), onError func(error)) error {
+	return errorWindowAPI.err
+}
+
 func TestJSONParsingLoopEmptyInput(t *testing.T) {
 	lines := strings.NewReader("")
 	errorCode := RunJSONParsingLoop(lines, nil, nil, nil, "#00FF00", false, 0)
@@ -121,3 +152,391 @@ func TestJSONParsingLoopGoodJSONInputFixedWidth(t *testing.T) {
 		t.Fatal("Expected to have fixed width output")
 	}
 }
+
+func TestJSONParsingLoopFakeWindowAPI(t *testing.T) {
+	script := "focus 1 Alacritty vim main.go\nfocus 2 firefox Mozilla Firefox\nretitle 2 GitHub"
+	windowAPI, err := window.NewFake(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []window.Event
+	windowAPI.DetectWindowTitleChanges(func(event window.Event) {
+		changes = append(changes, event)
+	}, func(err error) {
+		t.Fatal(err)
+	})
+	if len(changes) != 3 {
+		t.Fatalf("Unexpected changes %+v", changes)
+	}
+
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	lines := strings.NewReader(input)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	errorCode := RunJSONParsingLoop(lines, &stdout, &stderr, windowAPI, "#00FF00", false, 0)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	output := stdout.String()
+	if !strings.Contains(output, `[{"color":"#00FF00","full_text":"GitHub","name":"window_title"},{"color"`) {
+		t.Fatalf("Expected the last scripted title first, got %s", output)
+	}
+}
+
+func TestRunMultipleSources(t *testing.T) {
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	lines := strings.NewReader(input)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	sources := []TitleSource{
+		{Instance: ":0", API: TestWindowAPI{}},
+		{Instance: ":1", API: TestWindowAPI{}},
+	}
+	errorCode := Run(lines, &stdout, &stderr, sources, Options{Color: "#00FF00"})
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	output := stdout.String()
+	expected := `[{"color":"#00FF00","full_text":"foo","instance":":0","name":"window_title"},` +
+		`{"color":"#00FF00","full_text":"foo","instance":":1","name":"window_title"},{"color"`
+	if !strings.Contains(output, expected) {
+		t.Fatalf("Expected a title node for each display, got %s", output)
+	}
+}
+
+func TestRunPlaceholders(t *testing.T) {
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	sources := []TitleSource{
+		{Instance: "empty", API: errorWindowAPI{window.ErrNoActiveWindow}},
+		{Instance: "broken", API: errorWindowAPI{errors.New("kaboom")}},
+	}
+	options := Options{Color: "#00FF00", NoWindowText: "desktop", NoWindowColor: "#888888", ErrorText: "???", ErrorColor: "#FF0000"}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	output := stdout.String()
+	expected := `[{"color":"#888888","full_text":"desktop","instance":"empty","name":"window_title"},` +
+		`{"color":"#FF0000","full_text":"???","instance":"broken","name":"window_title"},{"color"`
+	if !strings.Contains(output, expected) {
+		t.Fatalf("Expected placeholders, got %s", output)
+	}
+
+	// unstyled placeholders fall back to the title color and can be omitted
+	options = Options{Color: "#00FF00", NoWindowText: "desktop", OmitNoWindow: true, ErrorText: "???"}
+	stdout.Reset()
+	errorCode = Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	output = stdout.String()
+	expected = `[{"color":"#00FF00","full_text":"???","instance":"broken","name":"window_title"},{"color"`
+	if !strings.Contains(output, expected) {
+		t.Fatalf("Expected omitted no window node, got %s", output)
+	}
+}
+
+func TestRunFormatAndWorkspaceBlock(t *testing.T) {
+	script := "workspace 2:code\nfocus 1 Alacritty vim main.go"
+	windowAPI, err := window.NewFake(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	sources := []TitleSource{{API: windowAPI}}
+	options := Options{Color: "#00FF00", Format: "{class} [{workspace}] {title}", WorkspaceBlock: true}
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	output := stdout.String()
+	expected := `[{"color":"#00FF00","full_text":"2:code","name":"workspace"},` +
+		`{"color":"#00FF00","full_text":"Alacritty [2:code] vim main.go","name":"window_title"},{"color"`
+	if !strings.Contains(output, expected) {
+		t.Fatalf("Expected workspace node and formatted title, got %s", output)
+	}
+}
+
+func TestRunUrgentBlock(t *testing.T) {
+	script := "focus 2 Slack chat\nfocus 3 Thunderbird mail\nfocus 1 Alacritty vim main.go\nurgent 2 on\nurgent 3 on\nurgent 1 on"
+	windowAPI, err := window.NewFake(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	sources := []TitleSource{{API: windowAPI}}
+	options := Options{Color: "#00FF00", UrgentBlock: true}
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	output := stdout.String()
+	expected := `[{"color":"#00FF00","full_text":"vim main.go","name":"window_title","urgent":true},` +
+		`{"color":"#00FF00","full_text":"vim main.go | chat | mail","name":"urgent_windows","urgent":true},{"color"`
+	if !strings.Contains(output, expected) {
+		t.Fatalf("Expected urgent title and urgent node, got %s", output)
+	}
+}
+
+func TestRunUrgentBlockOmitted(t *testing.T) {
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	sources := []TitleSource{{API: TestWindowAPI{}}}
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, Options{Color: "#00FF00", UrgentBlock: true})
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	if strings.Contains(stdout.String(), "urgent") {
+		t.Fatalf("Expected no urgent node, got %s", stdout.String())
+	}
+}
+
+func TestRunRewrites(t *testing.T) {
+	windowAPI, err := window.NewFake(strings.NewReader("focus 1 firefox GitHub — Mozilla Firefox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	sources := []TitleSource{{API: windowAPI}}
+	rewrites := rules.Rewrites{{Class: "firefox", Pattern: regexp.MustCompile(` — Mozilla Firefox$`)}}
+	options := Options{Color: "#00FF00", Format: "[{title}]", FixedWidth: 10, Rewrites: rewrites}
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	if !strings.Contains(stdout.String(), `"full_text":"[GitHub]  "`) {
+		t.Fatalf("Expected rewritten title, got %s", stdout.String())
+	}
+}
+
+func TestRunColorRules(t *testing.T) {
+	windowAPI, err := window.NewFake(strings.NewReader("focus 1 Alacritty ssh prod-db1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	sources := []TitleSource{{API: windowAPI}}
+	options := Options{
+		Color: "#00FF00",
+		// the colors match the title from before the rewrite
+		Rewrites: rules.Rewrites{{Pattern: regexp.MustCompile(`^ssh `)}},
+		Colors: rules.Colors{
+			{Class: "firefox", Color: "#FF8800"},
+			{Title: regexp.MustCompile(`^ssh prod-`), Background: "#CC0000", Border: "#FFFFFF"},
+			{Color: "#0000FF"},
+		},
+	}
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	expected := `[{"background":"#CC0000","border":"#FFFFFF","color":"#00FF00","full_text":"prod-db1","name":"window_title"},`
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("Expected styled title, got %s", stdout.String())
+	}
+}
+
+func TestRunMasksAndPrivacy(t *testing.T) {
+	script := "focus 2 Slack DM Alice\nfocus 1 KeePassXC bank - KeePassXC\nurgent 2 on"
+	windowAPI, err := window.NewFake(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]` +
+		"\n" +
+		`,[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 064%"}]`
+	sources := []TitleSource{{API: windowAPI}}
+	options := Options{
+		Color:       "#00FF00",
+		UrgentBlock: true,
+		Format:      "{class}: {title}",
+		Masks: rules.Masks{
+			{Class: "keepassxc", Text: "passwords"},
+			{Class: "Slack", Title: regexp.MustCompile(`^DM `)},
+		},
+		MaskText: "***",
+		Private:  &atomic.Bool{},
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	expected := `[{"color":"#00FF00","full_text":"passwords","name":"window_title"},` +
+		`{"color":"#00FF00","full_text":"***","name":"urgent_windows","urgent":true},{"color"`
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("Expected masked titles, got %s", stdout.String())
+	}
+
+	// privacy mode hides everything, even windows without a mask
+	windowAPI, _ = window.NewFake(strings.NewReader("focus 3 Alacritty vim main.go"))
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+	sources = []TitleSource{{API: windowAPI}}
+	options.Private.Store(true)
+	stdout.Reset()
+	errorCode = Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	if strings.Contains(stdout.String(), "vim") || !strings.Contains(stdout.String(), `"full_text":"***"`) {
+		t.Fatalf("Expected every title hidden, got %s", stdout.String())
+	}
+}
+
+func TestRunMaskedColorRules(t *testing.T) {
+	windowAPI, err := window.NewFake(strings.NewReader("focus 1 KeePassXC bank - KeePassXC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	sources := []TitleSource{{API: windowAPI}}
+	options := Options{
+		Color:   "#00FF00",
+		Masks:   rules.Masks{{Class: "keepassxc"}},
+		Colors:  rules.Colors{{Title: regexp.MustCompile(`^bank`), Color: "#FF0000", Background: "#CC0000"}},
+		Private: &atomic.Bool{},
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	for _, private := range []bool{false, true} {
+		if private {
+			// privacy mode hides the title of a window without a mask too
+			options.Masks = nil
+			options.Private.Store(true)
+		}
+		stdout.Reset()
+		errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+		if errorCode != OK {
+			t.Fatal("Expected no error from parsing loop")
+		}
+		expected := `[{"color":"#00FF00","full_text":"` + rules.DefaultMaskText + `","name":"window_title"},`
+		if !strings.Contains(stdout.String(), expected) {
+			t.Fatalf("Expected the default color for a hidden title with privacy %v, got %s", private, stdout.String())
+		}
+	}
+}
+
+func TestRunLiveSwapsOptions(t *testing.T) {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	var stderr bytes.Buffer
+	live := NewLiveOptions(Options{Color: "#00FF00"})
+	sources := []TitleSource{{API: TestWindowAPI{}}}
+	done := make(chan int)
+	go func() {
+		done <- RunLive(stdinReader, stdoutWriter, &stderr, sources, live)
+		stdoutWriter.Close()
+	}()
+
+	status := `[{"name":"wireless","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	output := bufio.NewScanner(stdoutReader)
+	nextLine := func(line string) string {
+		fmt.Fprintln(stdinWriter, line)
+		if !output.Scan() {
+			t.Fatal("Expected an output line")
+		}
+		return output.Text()
+	}
+	nextLine(`{"version":1}`)
+	nextLine("[")
+	if line := nextLine(status); !strings.Contains(line, `"color":"#00FF00","full_text":"foo"`) {
+		t.Fatalf("Expected the first options, got %s", line)
+	}
+
+	live.Store(Options{Color: "#FF0000"})
+	live.Update(func(options *Options) { options.Format = "[{title}]" })
+	if line := nextLine("," + status); !strings.Contains(line, `"color":"#FF0000","full_text":"[foo]"`) {
+		t.Fatalf("Expected the stored options, got %s", line)
+	}
+
+	stdinWriter.Close()
+	if errorCode := <-done; errorCode != OK {
+		t.Fatalf("Expected no error from parsing loop, got %d", errorCode)
+	}
+}
+
+func TestRunOverride(t *testing.T) {
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	sources := []TitleSource{
+		{Instance: "window", API: TestWindowAPI{}},
+		{Instance: "empty", API: errorWindowAPI{window.ErrNoActiveWindow}},
+	}
+	override := &atomic.Pointer[Override]{}
+	override.Store(&Override{Text: "recording"})
+	options := Options{Color: "#00FF00", Format: "[{title}]", FixedWidth: 12, OmitNoWindow: true, Override: override}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+	if errorCode != OK {
+		t.Fatal("Expected no error from parsing loop")
+	}
+	expected := `[{"color":"#00FF00","full_text":"recording   ","instance":"window","name":"window_title"},` +
+		`{"color":"#00FF00","full_text":"recording   ","instance":"empty","name":"window_title"},{"color"`
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("Expected the override text, got %s", stdout.String())
+	}
+}
+
+func TestCurrentOverride(t *testing.T) {
+	now := time.Now()
+	override := &atomic.Pointer[Override]{}
+	options := Options{Override: override}
+	if _, found := currentOverride(Options{}, now); found {
+		t.Fatal("Expected no override without one")
+	}
+	if _, found := currentOverride(options, now); found {
+		t.Fatal("Expected no override before one is stored")
+	}
+
+	override.Store(&Override{Text: "brb", Until: now.Add(time.Minute)})
+	if text, found := currentOverride(options, now); !found || text != "brb" {
+		t.Fatalf("Expected the override, got %q", text)
+	}
+	if _, found := currentOverride(options, now.Add(time.Minute)); found {
+		t.Fatal("Expected the override to expire")
+	}
+
+	override.Store(&Override{})
+	if _, found := currentOverride(options, now); found {
+		t.Fatal("Expected an empty override to clear it")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// Get the icon configured for the class of the given window, falling back to
+// its instance and then to the default icon.
+func windowIcon(info window.Info, options Options) string {
+	for _, name := range []string{info.Class, info.Instance} {
+		if name == "" {
+			continue
+		}
+		if icon, found := options.Icons[strings.ToLower(name)]; found {
+			return icon
+		}
+	}
+	return options.DefaultIcon
+}
+
+// Get the number of columns the given text takes up on the bar, counting wide
+// characters like CJK and emoji as two columns and combining marks as none.
+func displayWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// The East Asian Wide and Fullwidth ranges of Unicode 15.1, which include the
+// emoji shown with an emoji presentation by default, in order.
+var wideRanges = []struct {
+	first rune
+	last  rune
+}{
+	{0x1100, 0x115F}, // Hangul Jamo
+	{0x231A, 0x231B}, // watch and hourglass
+	{0x2329, 0x232A}, // angle brackets
+	{0x23E9, 0x23EC}, // media controls
+	{0x23F0, 0x23F0}, // alarm clock
+	{0x23F3, 0x23F3}, // hourglass
+	{0x25FD, 0x25FE}, // squares
+	{0x2614, 0x2615}, // umbrella and hot beverage
+	{0x2648, 0x2653}, // zodiac
+	{0x267F, 0x267F}, // wheelchair
+	{0x2693, 0x2693}, // anchor
+	{0x26A1, 0x26A1}, // high voltage
+	{0x26AA, 0x26AB}, // circles
+	{0x26BD, 0x26BE}, // soccer and baseball
+	{0x26C4, 0x26C5}, // snowman and sun
+	{0x26CE, 0x26CE}, // Ophiuchus
+	{0x26D4, 0x26D4}, // no entry
+	{0x26EA, 0x26EA}, // church
+	{0x26F2, 0x26F3}, // fountain and golf
+	{0x26F5, 0x26F5}, // sailboat
+	{0x26FA, 0x26FA}, // tent
+	{0x26FD, 0x26FD}, // fuel pump
+	{0x2705, 0x2705}, // check mark
+	{0x270A, 0x270B}, // fists
+	{0x2728, 0x2728}, // sparkles
+	{0x274C, 0x274C}, // cross mark
+	{0x274E, 0x274E}, // cross mark button
+	{0x2753, 0x2755}, // question and exclamation marks
+	{0x2757, 0x2757}, // exclamation mark
+	{0x2795, 0x2797}, // plus, minus and division
+	{0x27B0, 0x27B0}, // curly loop
+	{0x27BF, 0x27BF}, // double curly loop
+	{0x2B1B, 0x2B1C}, // large squares
+	{0x2B50, 0x2B50}, // star
+	{0x2B55, 0x2B55}, // circle
+	{0x2E80, 0x2E99}, // CJK radicals
+	{0x2E9B, 0x2EF3},
+	{0x2F00, 0x2FD5}, // Kangxi radicals
+	{0x2FF0, 0x2FFF}, // ideographic description
+	{0x3000, 0x303E}, // CJK symbols and punctuation
+	{0x3041, 0x3096}, // hiragana
+	{0x3099, 0x30FF}, // katakana
+	{0x3105, 0x312F}, // bopomofo
+	{0x3131, 0x318E}, // Hangul compatibility Jamo
+	{0x3190, 0x31E3}, // kanbun and CJK strokes
+	{0x31EF, 0x321E},
+	{0x3220, 0x3247}, // enclosed CJK
+	{0x3250, 0x4DBF}, // enclosed CJK, compatibility and extension A
+	{0x4E00, 0xA48C}, // CJK unified ideographs and Yi
+	{0xA490, 0xA4C6}, // Yi radicals
+	{0xA960, 0xA97C}, // Hangul Jamo extended A
+	{0xAC00, 0xD7A3}, // Hangul syllables
+	{0xF900, 0xFAFF}, // CJK compatibility ideographs
+	{0xFE10, 0xFE19}, // vertical forms
+	{0xFE30, 0xFE52}, // CJK compatibility forms
+	{0xFE54, 0xFE66}, // small forms
+	{0xFE68, 0xFE6B},
+	{0xFF01, 0xFF60}, // fullwidth forms
+	{0xFFE0, 0xFFE6},
+	{0x16FE0, 0x16FE4}, // ideographic symbols
+	{0x16FF0, 0x16FF1},
+	{0x17000, 0x187F7}, // Tangut
+	{0x18800, 0x18CD5}, // Tangut components and Khitan
+	{0x18D00, 0x18D08},
+	{0x1AFF0, 0x1AFF3}, // kana extended B
+	{0x1AFF5, 0x1AFFB},
+	{0x1AFFD, 0x1AFFE},
+	{0x1B000, 0x1B122}, // kana supplement and extended A
+	{0x1B132, 0x1B132},
+	{0x1B150, 0x1B152}, // small kana
+	{0x1B155, 0x1B155},
+	{0x1B164, 0x1B167},
+	{0x1B170, 0x1B2FB}, // Nushu
+	{0x1F004, 0x1F004}, // mahjong tile
+	{0x1F0CF, 0x1F0CF}, // joker
+	{0x1F18E, 0x1F18E}, // AB button
+	{0x1F191, 0x1F19A}, // squared letters
+	{0x1F200, 0x1F202}, // enclosed ideographs
+	{0x1F210, 0x1F23B},
+	{0x1F240, 0x1F248},
+	{0x1F250, 0x1F251},
+	{0x1F260, 0x1F265},
+	{0x1F300, 0x1F320}, // weather and landscapes
+	{0x1F32D, 0x1F335}, // food and plants
+	{0x1F337, 0x1F37C},
+	{0x1F37E, 0x1F393},
+	{0x1F3A0, 0x1F3CA}, // activities
+	{0x1F3CF, 0x1F3D3},
+	{0x1F3E0, 0x1F3F0}, // buildings
+	{0x1F3F4, 0x1F3F4}, // flag
+	{0x1F3F8, 0x1F43E}, // sports, animals
+	{0x1F440, 0x1F440}, // eyes
+	{0x1F442, 0x1F4FC}, // people and objects
+	{0x1F4FF, 0x1F53D},
+	{0x1F54B, 0x1F54E}, // religion
+	{0x1F550, 0x1F567}, // clock faces
+	{0x1F57A, 0x1F57A}, // dancer
+	{0x1F595, 0x1F596}, // hands
+	{0x1F5A4, 0x1F5A4}, // black heart
+	{0x1F5FB, 0x1F64F}, // landmarks and faces
+	{0x1F680, 0x1F6C5}, // transport and map symbols
+	{0x1F6CC, 0x1F6CC},
+	{0x1F6D0, 0x1F6D2},
+	{0x1F6D5, 0x1F6D7},
+	{0x1F6DC, 0x1F6DF},
+	{0x1F6EB, 0x1F6EC},
+	{0x1F6F4, 0x1F6FC},
+	{0x1F7E0, 0x1F7EB}, // colored circles and squares
+	{0x1F7F0, 0x1F7F0},
+	{0x1F90C, 0x1F93A}, // supplemental symbols and pictographs
+	{0x1F93C, 0x1F945},
+	{0x1F947, 0x1F9FF},
+	{0x1FA70, 0x1FA7C}, // symbols and pictographs extended A
+	{0x1FA80, 0x1FA88},
+	{0x1FA90, 0x1FABD},
+	{0x1FABF, 0x1FAC5},
+	{0x1FACE, 0x1FADB},
+	{0x1FAE0, 0x1FAE8},
+	{0x1FAF0, 0x1FAF8},
+	{0x20000, 0x2FFFD}, // CJK extensions B to F
+	{0x30000, 0x3FFFD}, // CJK extensions G and later
+}
+
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	i := sort.Search(len(wideRanges), func(i int) bool {
+		return wideRanges[i].last >= r
+	})
+	if i < len(wideRanges) && wideRanges[i].first <= r {
+		return 2
+	}
+	return 1
+}
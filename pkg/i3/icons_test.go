@@ -0,0 +1,106 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+func TestWindowIcon(t *testing.T) {
+	options := Options{Icons: map[string]string{"firefox": "", "alacritty": ""}, DefaultIcon: "*"}
+	windows := map[string]window.Info{
+		"": {Class: "firefox", Instance: "Navigator"},
+		"": {Class: "Alacritty", Instance: "alacritty"},
+		"*": {Class: "Slack", Instance: "slack"},
+	}
+	for expected, info := range windows {
+		if icon := windowIcon(info, options); icon != expected {
+			t.Fatalf("Expected %q for %+v, got %q", expected, info, icon)
+		}
+	}
+	if icon := windowIcon(window.Info{Instance: "Firefox"}, options); icon != "" {
+		t.Fatalf("Expected the instance to match, got %q", icon)
+	}
+	if icon := windowIcon(window.Info{Class: "Slack"}, Options{}); icon != "" {
+		t.Fatalf("Expected no icon, got %q", icon)
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	widths := map[string]int{
+		"vim":       3,
+		"":         1,
+		"🦊 firefox": 10,
+		"日本語":       6,
+		"été":      3,
+		"🚀🛸":        4, // transport and map symbols
+		"🪐🫠":        4, // symbols and pictographs extended A
+		"☕⚡✅❌":      8, // emoji presentation in the miscellaneous symbols and dingbats
+		"☀✂":        2, // text presentation next to them
+		"ＡＢ":        4,
+	}
+	for text, expected := range widths {
+		if width := displayWidth(text); width != expected {
+			t.Fatalf("Expected width %d for %q, got %d", expected, text, width)
+		}
+	}
+}
+
+func TestTruncateAndPadWide(t *testing.T) {
+	values := map[string]string{
+		" Mozilla Firefox": " Mozil",
+		"🦊 Firefox":         "🦊 Fire",
+		"日本語のタイトル":          "日本語 ",
+		"vim":               "vim    ",
+		"🚀 launch":          "🚀 laun",
+	}
+	for value, expected := range values {
+		if padded := truncateAndPad(value, 7); padded != expected {
+			t.Fatalf("Expected %q for %q, got %q", expected, value, padded)
+		}
+	}
+}
+
+func TestRunIcons(t *testing.T) {
+	input := "\n\n" +
+		`[{"name":"wireless","instance":"wlp1s0","color":"#00FF00","markup":"none","full_text":"W: SOME_WIFI_SSID 067%"}]`
+	windowAPI, err := window.NewFake(strings.NewReader("focus 1 firefox Mozilla Firefox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windowAPI.DetectWindowTitleChanges(func(window.Event) {}, func(error) {})
+	sources := []TitleSource{{API: windowAPI}}
+
+	formats := map[string]string{
+		"":                 `"full_text":"B Mozilla Firefox   "`,
+		"{title} [{icon}]": `"full_text":"Mozilla Firefox [B] "`,
+	}
+	for format, expected := range formats {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+		options := Options{Color: "#00FF00", Format: format, FixedWidth: 20, Icons: map[string]string{"firefox": "B"}}
+		errorCode := Run(strings.NewReader(input), &stdout, &stderr, sources, options)
+		if errorCode != OK {
+			t.Fatal("Expected no error from parsing loop")
+		}
+		if !strings.Contains(stdout.String(), expected) {
+			t.Fatalf("Expected %s for %q, got %s", expected, format, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"strings"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// DefaultFormat shows nothing but the window title.
+const DefaultFormat = "{title}"
+
+// Collect the fields available to a format template for the given window.
+func templateFields(info window.Info, workspace string) map[string]string {
+	return map[string]string{
+		"title":     info.Title,
+		"class":     info.Class,
+		"instance":  info.Instance,
+		"role":      info.Role,
+		"workspace": workspace,
+	}
+}
+
+// Replace every {name} in the given template with the value of that field.
+// Unknown fields are left as they are so typos are easy to spot on the bar.
+func expandTemplate(template string, fields map[string]string) string {
+	var expanded strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		expanded.WriteString(template[:start])
+		if value, found := fields[template[start+1:end]]; found {
+			expanded.WriteString(value)
+		} else {
+			expanded.WriteString(template[start : end+1])
+		}
+		template = template[end+1:]
+	}
+	expanded.WriteString(template)
+	return expanded.String()
+}
+
+// Report whether the given template uses the named field.
+func templateUses(template string, name string) bool {
+	return strings.Contains(template, "{"+name+"}")
+}
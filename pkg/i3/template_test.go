@@ -0,0 +1,47 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	fields := templateFields(window.Info{Title: "vim main.go", Class: "Alacritty"}, "2:code")
+	templates := map[string]string{
+		"{title}":                "vim main.go",
+		"[{workspace}] {title}":  "[2:code] vim main.go",
+		"{class}: {title}{role}": "Alacritty: vim main.go",
+		"{potato} {title":        "{potato} {title",
+		"no fields":              "no fields",
+		"":                       "",
+	}
+	for template, expected := range templates {
+		if expanded := expandTemplate(template, fields); expanded != expected {
+			t.Fatalf("Expected %q for %q, got %q", expected, template, expanded)
+		}
+	}
+}
+
+func TestTemplateUses(t *testing.T) {
+	if !templateUses("[{workspace}] {title}", "workspace") {
+		t.Fatal("Expected workspace to be used")
+	}
+	if templateUses(DefaultFormat, "workspace") {
+		t.Fatal("Expected workspace to be unused")
+	}
+}
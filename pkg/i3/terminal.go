@@ -0,0 +1,99 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/process"
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// DefaultTerminalClasses are the window classes treated as terminal emulators
+// when Options.TerminalClasses is empty.
+var DefaultTerminalClasses = []string{
+	"Alacritty",
+	"kitty",
+	"foot",
+	"XTerm",
+	"URxvt",
+	"st-256color",
+	"Gnome-terminal",
+	"konsole",
+	"xfce4-terminal",
+	"org.wezfurlong.wezterm",
+	"Terminator",
+	"Tilix",
+}
+
+// DefaultTerminalTimeout caps how long a walk through /proc can take when
+// Options.TerminalTimeout isn't set.
+const DefaultTerminalTimeout = 50 * time.Millisecond
+
+// This is how the foreground process is found, swapped out by tests.
+var foregroundProcess = func(terminalPID int, timeout time.Duration) (*process.Info, error) {
+	return process.ForegroundProcess(process.ProcRoot, terminalPID, timeout)
+}
+
+// Report whether the given window belongs to one of the given terminal classes.
+func isTerminal(info window.Info, classes []string) bool {
+	if len(classes) == 0 {
+		classes = DefaultTerminalClasses
+	}
+	for _, class := range classes {
+		if strings.EqualFold(class, info.Class) || strings.EqualFold(class, info.Instance) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add the {command} and {cwd} fields for the process in the foreground of the
+// given terminal window. They're left empty for anything that isn't a terminal
+// or when the process tree can't be read in time.
+func addTerminalFields(fields map[string]string, info window.Info, options Options) {
+	fields["command"], fields["cwd"] = "", ""
+	if info.PID <= 0 || !isTerminal(info, options.TerminalClasses) {
+		return
+	}
+
+	timeout := options.TerminalTimeout
+	if timeout <= 0 {
+		timeout = DefaultTerminalTimeout
+	}
+	foreground, err := foregroundProcess(info.PID, timeout)
+	if err != nil {
+		return
+	}
+	fields["command"] = foreground.Command
+	fields["cwd"] = shortenHome(foreground.Cwd, os.Getenv("HOME"))
+}
+
+// Replace the given home directory at the start of the path with ~.
+func shortenHome(path string, home string) string {
+	home = strings.TrimSuffix(home, "/")
+	if home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+"/") {
+		return "~" + path[len(home):]
+	}
+	return path
+}
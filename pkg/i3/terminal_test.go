@@ -0,0 +1,114 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/process"
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// Replace the /proc walk with one that always finds the given process.
+func useForegroundProcess(t *testing.T, info *process.Info, err error) *int {
+	calls := 0
+	original := foregroundProcess
+	foregroundProcess = func(terminalPID int, timeout time.Duration) (*process.Info, error) {
+		calls++
+		return info, err
+	}
+	t.Cleanup(func() { foregroundProcess = original })
+	return &calls
+}
+
+func TestAddTerminalFields(t *testing.T) {
+	t.Setenv("HOME", "/home/ray")
+	useForegroundProcess(t, &process.Info{PID: 200, Command: "vim main.go", Cwd: "/home/ray/src/project"}, nil)
+
+	info := window.Info{Title: "Terminal", Class: "alacritty", PID: 100}
+	fields := templateFields(info, "")
+	addTerminalFields(fields, info, Options{})
+
+	expanded := expandTemplate("{command} — {cwd}", fields)
+	if expanded != "vim main.go — ~/src/project" {
+		t.Fatalf("Unexpected expansion %q", expanded)
+	}
+}
+
+func TestAddTerminalFieldsNotTerminal(t *testing.T) {
+	calls := useForegroundProcess(t, &process.Info{PID: 200, Command: "vim main.go", Cwd: "/"}, nil)
+
+	infos := []window.Info{
+		{Title: "Mozilla Firefox", Class: "firefox", PID: 100},
+		{Title: "Terminal", Class: "Alacritty"},
+	}
+	for _, info := range infos {
+		fields := templateFields(info, "")
+		addTerminalFields(fields, info, Options{})
+		if fields["command"] != "" || fields["cwd"] != "" {
+			t.Fatalf("Expected empty fields for %+v, got %v", info, fields)
+		}
+	}
+	if *calls != 0 {
+		t.Fatalf("Expected no /proc walks, got %d", *calls)
+	}
+}
+
+func TestAddTerminalFieldsCustomClasses(t *testing.T) {
+	useForegroundProcess(t, &process.Info{PID: 200, Command: "htop", Cwd: "/"}, nil)
+
+	info := window.Info{Title: "Terminal", Class: "Alacritty", Instance: "my-term", PID: 100}
+	fields := templateFields(info, "")
+	addTerminalFields(fields, info, Options{TerminalClasses: []string{"My-Term"}})
+	if fields["command"] != "htop" {
+		t.Fatalf("Expected the instance to match, got %v", fields)
+	}
+
+	fields = templateFields(info, "")
+	addTerminalFields(fields, info, Options{TerminalClasses: []string{"kitty"}})
+	if fields["command"] != "" {
+		t.Fatalf("Expected the default classes to be replaced, got %v", fields)
+	}
+}
+
+func TestAddTerminalFieldsError(t *testing.T) {
+	useForegroundProcess(t, nil, errors.New("kaboom"))
+
+	info := window.Info{Title: "Terminal", Class: "kitty", PID: 100}
+	fields := templateFields(info, "")
+	addTerminalFields(fields, info, Options{})
+	if fields["command"] != "" || fields["cwd"] != "" {
+		t.Fatalf("Expected empty fields, got %v", fields)
+	}
+}
+
+func TestShortenHome(t *testing.T) {
+	paths := map[string]string{
+		"/home/ray":             "~",
+		"/home/ray/src/project": "~/src/project",
+		"/home/raymond":         "/home/raymond",
+		"/tmp":                  "/tmp",
+	}
+	for path, expected := range paths {
+		if shortened := shortenHome(path, "/home/ray/"); shortened != expected {
+			t.Fatalf("Expected %q for %q, got %q", expected, path, shortened)
+		}
+	}
+	if shortened := shortenHome("/tmp", ""); shortened != "/tmp" {
+		t.Fatalf("Expected no change without a home, got %q", shortened)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcRoot is where the kernel exposes process information.
+const ProcRoot = "/proc"
+
+var ErrNoShell = errors.New("no child process with a terminal could be found")
+var ErrProcTimeout = errors.New("timed out walking the process tree")
+
+// Info describes a running process.
+type Info struct {
+	// PID is the process identifier.
+	PID int
+
+	// Command is the full command line with arguments separated by spaces.
+	Command string
+
+	// Cwd is the current working directory.
+	Cwd string
+}
+
+// stat holds the fields of /proc/PID/stat that matter here.
+type stat struct {
+	pid       int
+	ppid      int
+	pgrp      int
+	tty       int
+	tpgid     int
+	starttime uint64
+}
+
+// ForegroundProcess finds the process in the foreground of the terminal owned
+// by the given terminal emulator process, reading processes from the given
+// proc root. That's the leader of the foreground process group of the
+// terminal its children are attached to, which is the shell itself when it's
+// sitting at a prompt. Only the descendants of the terminal emulator are read,
+// following the children of each of their threads. A terminal with several
+// tabs has several terminals, the one where something was started most
+// recently wins. Since reads in /proc can block on a hung process,
+// ErrProcTimeout is returned once the timeout passes even when a read is
+// still stuck.
+func ForegroundProcess(procRoot string, terminalPID int, timeout time.Duration) (*Info, error) {
+	deadline := time.Now().Add(timeout)
+	type result struct {
+		info *Info
+		err  error
+	}
+	// buffered so the walk can finish and be collected after a timeout
+	done := make(chan result, 1)
+	go func() {
+		info, err := foregroundProcess(procRoot, terminalPID, deadline)
+		done <- result{info, err}
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result.info, result.err
+	case <-timer.C:
+		return nil, ErrProcTimeout
+	}
+}
+
+func foregroundProcess(procRoot string, terminalPID int, deadline time.Time) (*Info, error) {
+	children, err := readChildren(procRoot, terminalPID)
+	if err != nil {
+		return nil, err
+	}
+
+	var foreground *stat
+	shells := 0
+	for _, pid := range children {
+		if time.Now().After(deadline) {
+			return nil, ErrProcTimeout
+		}
+		shell, err := readStat(procRoot, pid)
+		if err != nil || shell.tty == 0 || shell.tpgid <= 0 {
+			// gone already or not attached to a terminal
+			continue
+		}
+		shells++
+		found, err := foregroundOf(procRoot, shell, deadline)
+		if err != nil {
+			return nil, err
+		}
+		if found != nil && (foreground == nil || newerForeground(found, foreground)) {
+			foreground = found
+		}
+	}
+	if shells == 0 || foreground == nil {
+		return nil, ErrNoShell
+	}
+	return readInfo(procRoot, foreground.pid)
+}
+
+// Find the process in the foreground group of the terminal of the given shell,
+// usually the group leader, or else the newest one left in the group among
+// the shell and its descendants.
+func foregroundOf(procRoot string, shell *stat, deadline time.Time) (*stat, error) {
+	if leader, err := readStat(procRoot, shell.tpgid); err == nil && leader.pgrp == shell.tpgid && leader.tty == shell.tty {
+		return leader, nil
+	}
+
+	var foreground *stat
+	pending := []*stat{shell}
+	for len(pending) > 0 {
+		if time.Now().After(deadline) {
+			return nil, ErrProcTimeout
+		}
+		process := pending[0]
+		pending = pending[1:]
+		if process.pgrp == shell.tpgid && process.tty == shell.tty &&
+			(foreground == nil || newerForeground(process, foreground)) {
+			foreground = process
+		}
+		children, _ := readChildren(procRoot, process.pid)
+		for _, pid := range children {
+			if child, err := readStat(procRoot, pid); err == nil {
+				pending = append(pending, child)
+			}
+		}
+	}
+	return foreground, nil
+}
+
+// Read the children of every thread of the given process.
+func readChildren(procRoot string, pid int) ([]int, error) {
+	tasks, err := os.ReadDir(filepath.Join(procRoot, strconv.Itoa(pid), "task"))
+	if err != nil {
+		return nil, err
+	}
+	var children []int
+	for _, task := range tasks {
+		content, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "task", task.Name(), "children"))
+		if err != nil {
+			// the thread is gone already
+			continue
+		}
+		for _, field := range strings.Fields(string(content)) {
+			if child, err := strconv.Atoi(field); err == nil {
+				children = append(children, child)
+			}
+		}
+	}
+	return children, nil
+}
+
+// Report whether the given foreground process should be picked over the other.
+func newerForeground(stat *stat, other *stat) bool {
+	leader, otherLeader := stat.pid == stat.pgrp, other.pid == other.pgrp
+	if leader != otherLeader {
+		return leader
+	}
+	return stat.starttime > other.starttime
+}
+
+// Read the stat fields of the given process.
+func readStat(procRoot string, pid int) (*stat, error) {
+	content, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+	// the command name in parens can contain anything, so split after it
+	end := strings.LastIndexByte(string(content), ')')
+	if end < 0 {
+		return nil, errors.New("malformed stat for process " + strconv.Itoa(pid))
+	}
+	// state ppid pgrp session tty_nr tpgid flags minflt cminflt majflt cmajflt
+	// utime stime cutime cstime priority nice num_threads itrealvalue starttime
+	fields := strings.Fields(string(content[end+1:]))
+	if len(fields) < 20 {
+		return nil, errors.New("malformed stat for process " + strconv.Itoa(pid))
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	pgrp, _ := strconv.Atoi(fields[2])
+	tty, _ := strconv.Atoi(fields[4])
+	tpgid, _ := strconv.Atoi(fields[5])
+	starttime, _ := strconv.ParseUint(fields[19], 10, 64)
+	return &stat{pid: pid, ppid: ppid, pgrp: pgrp, tty: tty, tpgid: tpgid, starttime: starttime}, nil
+}
+
+// Read the command line and working directory of the given process.
+func readInfo(procRoot string, pid int) (*Info, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+	cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	command := strings.Join(strings.FieldsFunc(string(cmdline), func(r rune) bool { return r == 0 }), " ")
+
+	// a process owned by someone else hides its cwd, which is fine
+	cwd, _ := os.Readlink(filepath.Join(dir, "cwd"))
+	return &Info{PID: pid, Command: command, Cwd: cwd}, nil
+}
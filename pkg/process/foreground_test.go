@@ -0,0 +1,193 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// A process in a fake /proc.
+type fakeProcess struct {
+	pid       int
+	comm      string
+	ppid      int
+	pgrp      int
+	tty       int
+	tpgid     int
+	starttime int
+	cmdline   string
+	cwd       string
+}
+
+// Make a fake /proc holding the given processes.
+func fakeProc(t *testing.T, processes ...fakeProcess) string {
+	dir := t.TempDir()
+	for _, process := range processes {
+		processDir := filepath.Join(dir, fmt.Sprint(process.pid))
+		if err := os.MkdirAll(processDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		stat := fmt.Sprintf("%d (%s) S %d %d %d %d %d 4194560 0 0 0 0 0 0 0 0 20 0 1 0 %d 0 0",
+			process.pid, process.comm, process.ppid, process.pgrp, process.pgrp, process.tty, process.tpgid, process.starttime)
+		if err := os.WriteFile(filepath.Join(processDir, "stat"), []byte(stat), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(processDir, "cmdline"), []byte(process.cmdline), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(process.cwd, filepath.Join(processDir, "cwd")); err != nil {
+			t.Fatal(err)
+		}
+
+		// a single thread with every child
+		var children []string
+		for _, child := range processes {
+			if child.ppid == process.pid {
+				children = append(children, fmt.Sprint(child.pid))
+			}
+		}
+		taskDir := filepath.Join(processDir, "task", fmt.Sprint(process.pid))
+		if err := os.MkdirAll(taskDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(taskDir, "children"), []byte(strings.Join(children, " ")), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Replace the stat of the given fake process with one that blocks when read,
+// like the stat of a process hung in the kernel.
+func blockStat(t *testing.T, dir string, pid int) {
+	stat := filepath.Join(dir, fmt.Sprint(pid), "stat")
+	if err := os.Remove(stat); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(stat, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// let a stuck read finish
+		if writer, err := os.OpenFile(stat, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			writer.Close()
+		}
+	})
+}
+
+var alacritty = fakeProcess{pid: 100, comm: "alacritty", ppid: 1, pgrp: 100, tpgid: -1, starttime: 1000, cmdline: "alacritty\x00", cwd: "/home/ray"}
+
+func TestForegroundProcessRunningCommand(t *testing.T) {
+	dir := fakeProc(t,
+		alacritty,
+		fakeProcess{pid: 101, comm: "zsh", ppid: 100, pgrp: 101, tty: 34816, tpgid: 200, starttime: 1001, cmdline: "-zsh\x00", cwd: "/home/ray/src"},
+		fakeProcess{pid: 200, comm: "vim (1)", ppid: 101, pgrp: 200, tty: 34816, tpgid: 200, starttime: 1002, cmdline: "vim\x00main.go\x00", cwd: "/home/ray/src/project"},
+		fakeProcess{pid: 300, comm: "unrelated", ppid: 1, pgrp: 300, tty: 34817, tpgid: 300, starttime: 1003, cmdline: "unrelated\x00", cwd: "/"},
+	)
+	// processes outside the terminal's tree are never read
+	blockStat(t, dir, 300)
+
+	info, err := ForegroundProcess(dir, 100, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PID != 200 || info.Command != "vim main.go" || info.Cwd != "/home/ray/src/project" {
+		t.Fatalf("Unexpected process %+v", info)
+	}
+}
+
+func TestForegroundProcessIdleShell(t *testing.T) {
+	dir := fakeProc(t,
+		alacritty,
+		fakeProcess{pid: 101, comm: "zsh", ppid: 100, pgrp: 101, tty: 34816, tpgid: 101, starttime: 1001, cmdline: "-zsh\x00", cwd: "/home/ray/src"},
+		// a background job started after the shell
+		fakeProcess{pid: 400, comm: "sleep", ppid: 101, pgrp: 400, tty: 34816, tpgid: 101, starttime: 1005, cmdline: "sleep\x0060\x00", cwd: "/home/ray/src"},
+	)
+
+	info, err := ForegroundProcess(dir, 100, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PID != 101 || info.Command != "-zsh" || info.Cwd != "/home/ray/src" {
+		t.Fatalf("Unexpected process %+v", info)
+	}
+}
+
+func TestForegroundProcessLeaderGone(t *testing.T) {
+	dir := fakeProc(t,
+		alacritty,
+		fakeProcess{pid: 101, comm: "zsh", ppid: 100, pgrp: 101, tty: 34816, tpgid: 200, starttime: 1001, cmdline: "-zsh\x00", cwd: "/home/ray/src"},
+		fakeProcess{pid: 201, comm: "less", ppid: 101, pgrp: 200, tty: 34816, tpgid: 200, starttime: 1002, cmdline: "less\x00", cwd: "/home/ray/src"},
+	)
+
+	info, err := ForegroundProcess(dir, 100, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PID != 201 || info.Command != "less" {
+		t.Fatalf("Expected the rest of the foreground group, got %+v", info)
+	}
+}
+
+func TestForegroundProcessTabs(t *testing.T) {
+	// PIDs wrapped around, so the newest tab has the lowest PIDs
+	dir := fakeProc(t,
+		alacritty,
+		fakeProcess{pid: 50, comm: "zsh", ppid: 100, pgrp: 50, tty: 34817, tpgid: 60, starttime: 2000, cmdline: "-zsh\x00", cwd: "/"},
+		fakeProcess{pid: 60, comm: "htop", ppid: 50, pgrp: 60, tty: 34817, tpgid: 60, starttime: 2001, cmdline: "htop\x00", cwd: "/"},
+		fakeProcess{pid: 101, comm: "zsh", ppid: 100, pgrp: 101, tty: 34816, tpgid: 101, starttime: 1001, cmdline: "-zsh\x00", cwd: "/home/ray"},
+	)
+
+	info, err := ForegroundProcess(dir, 100, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PID != 60 || info.Command != "htop" {
+		t.Fatalf("Expected the most recently started foreground process, got %+v", info)
+	}
+}
+
+func TestForegroundProcessNoShell(t *testing.T) {
+	dir := fakeProc(t,
+		fakeProcess{pid: 100, comm: "firefox", ppid: 1, pgrp: 100, tpgid: -1, cmdline: "firefox\x00", cwd: "/home/ray"},
+		fakeProcess{pid: 101, comm: "firefox-bin", ppid: 100, pgrp: 100, tpgid: -1, cmdline: "firefox-bin\x00", cwd: "/home/ray"},
+	)
+
+	if _, err := ForegroundProcess(dir, 100, time.Second); err != ErrNoShell {
+		t.Fatalf("Expected no shell, got %v", err)
+	}
+}
+
+func TestForegroundProcessTimeout(t *testing.T) {
+	dir := fakeProc(t,
+		alacritty,
+		fakeProcess{pid: 101, comm: "zsh", ppid: 100, pgrp: 101, tty: 34816, tpgid: 101, starttime: 1001, cmdline: "-zsh\x00", cwd: "/home/ray/src"},
+	)
+	blockStat(t, dir, 101)
+
+	start := time.Now()
+	if _, err := ForegroundProcess(dir, 100, 50*time.Millisecond); err != ErrProcTimeout {
+		t.Fatalf("Expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the timeout to cut the read short, took %v", elapsed)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// Color styles the title of the windows it matches. A window has to match
+// every condition that is set, so a Color without any conditions matches
+// every window.
+type Color struct {
+	// Class matches the window class, ignoring case.
+	Class string
+
+	// Instance matches the window instance, ignoring case.
+	Instance string
+
+	// Title matches the original window title, before any Rewrites.
+	Title *regexp.Regexp
+
+	// Color is the text color, like #FF0000. Empty leaves it alone.
+	Color string
+
+	// Background is the background color. Empty leaves it alone.
+	Background string
+
+	// Border is the border color. Empty leaves it alone.
+	Border string
+}
+
+// Matches reports whether the rule applies to the given window.
+func (color Color) Matches(info window.Info) bool {
+	if color.Class != "" && !strings.EqualFold(color.Class, info.Class) {
+		return false
+	}
+	if color.Instance != "" && !strings.EqualFold(color.Instance, info.Instance) {
+		return false
+	}
+	if color.Title != nil && !color.Title.MatchString(info.Title) {
+		return false
+	}
+	return true
+}
+
+// Colors are tried in order and the first one matching a window wins.
+type Colors []Color
+
+// Find returns the index of the first rule matching the given window or -1
+// when none does.
+func (colors Colors) Find(info window.Info) int {
+	for i, color := range colors {
+		if color.Matches(info) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+var exampleColors = Colors{
+	{Class: "Alacritty", Title: regexp.MustCompile(`^ssh prod-`), Color: "#FFFFFF", Background: "#CC0000"},
+	{Class: "alacritty", Instance: "scratch", Color: "#888888"},
+	{Title: regexp.MustCompile(`(?i)private browsing`), Border: "#FF00FF"},
+}
+
+func TestColorsFind(t *testing.T) {
+	windows := []struct {
+		info     window.Info
+		expected int
+	}{
+		{window.Info{Class: "Alacritty", Instance: "Alacritty", Title: "ssh prod-db1"}, 0},
+		{window.Info{Class: "Alacritty", Instance: "scratch", Title: "ssh prod-db1"}, 0},
+		{window.Info{Class: "Alacritty", Instance: "scratch", Title: "htop"}, 1},
+		{window.Info{Class: "Alacritty", Instance: "Alacritty", Title: "ssh staging-db1"}, -1},
+		{window.Info{Class: "firefox", Title: "GitHub — Private Browsing"}, 2},
+		{window.Info{Class: "xterm", Instance: "scratch", Title: "htop"}, -1},
+	}
+	for _, w := range windows {
+		if found := exampleColors.Find(w.info); found != w.expected {
+			t.Fatalf("Expected rule %d for %+v, got %d", w.expected, w.info, found)
+		}
+	}
+}
+
+func TestColorsCatchAll(t *testing.T) {
+	colors := Colors{{Color: "#00FF00"}}
+	if colors.Find(window.Info{}) != 0 {
+		t.Fatal("Expected a rule without conditions to match everything")
+	}
+	if (Colors{}).Find(window.Info{}) != -1 {
+		t.Fatal("Expected no rules to match nothing")
+	}
+}
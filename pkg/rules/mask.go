@@ -0,0 +1,110 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// DefaultMaskText replaces masked titles when neither the Mask nor the caller
+// has anything else to show.
+const DefaultMaskText = "(hidden)"
+
+// IncognitoTitle matches the markers browsers put in the titles of private
+// windows.
+var IncognitoTitle = regexp.MustCompile(`(?i)\b(private browsing|incognito|inprivate)\b`)
+
+// Mask hides the title of the windows it matches, like password managers or
+// private browsing windows. A window has to match every condition that is set.
+type Mask struct {
+	// Class matches the window class or instance, ignoring case.
+	Class string
+
+	// State matches one of the window states, like "hidden".
+	State string
+
+	// Title matches the original window title, before any Rewrites.
+	Title *regexp.Regexp
+
+	// Incognito matches windows with IncognitoTitle markers in their title.
+	Incognito bool
+
+	// Text is shown instead of the title, the caller's default when empty.
+	Text string
+}
+
+// Matches reports whether the given window should be masked. A Mask without
+// any conditions never matches, hiding everything is what privacy mode is for.
+func (mask Mask) Matches(info window.Info) bool {
+	if mask.Class == "" && mask.State == "" && mask.Title == nil && !mask.Incognito {
+		return false
+	}
+	if mask.Class != "" && !strings.EqualFold(mask.Class, info.Class) && !strings.EqualFold(mask.Class, info.Instance) {
+		return false
+	}
+	if mask.State != "" && !containsFold(info.States, mask.State) {
+		return false
+	}
+	if mask.Title != nil && !mask.Title.MatchString(info.Title) {
+		return false
+	}
+	if mask.Incognito && !IncognitoTitle.MatchString(info.Title) {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Masks are tried in order and the first one matching a window hides it.
+type Masks []Mask
+
+// Find returns the index of the first mask matching the given window or -1
+// when none does.
+func (masks Masks) Find(info window.Info) int {
+	for i, mask := range masks {
+		if mask.Matches(info) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Text returns what to show instead of the title of the given window, using
+// defaultText when the matching Mask has no Text of its own, and whether the
+// window is masked at all.
+func (masks Masks) Text(info window.Info, defaultText string) (string, bool) {
+	found := masks.Find(info)
+	if found < 0 {
+		return "", false
+	}
+	if masks[found].Text != "" {
+		return masks[found].Text, true
+	}
+	if defaultText != "" {
+		return defaultText, true
+	}
+	return DefaultMaskText, true
+}
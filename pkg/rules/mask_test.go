@@ -0,0 +1,71 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+var exampleMasks = Masks{
+	{Class: "KeePassXC", Text: "passwords"},
+	{Class: "Slack", Title: regexp.MustCompile(`^DM `)},
+	{State: "hidden"},
+	{Incognito: true},
+}
+
+func TestMasksFind(t *testing.T) {
+	windows := []struct {
+		info     window.Info
+		expected int
+	}{
+		{window.Info{Class: "keepassxc", Title: "bank - KeePassXC"}, 0},
+		{window.Info{Class: "Slack", Title: "DM Alice"}, 1},
+		{window.Info{Class: "Slack", Title: "#general"}, -1},
+		{window.Info{Class: "mpv", Title: "movie.mkv", States: []string{"sticky", "hidden"}}, 2},
+		{window.Info{Class: "firefox", Title: "GitHub — Mozilla Firefox Private Browsing"}, 3},
+		{window.Info{Class: "Chromium", Title: "New Tab (Incognito)"}, 3},
+		{window.Info{Class: "firefox", Title: "Incognitomode docs"}, -1},
+		{window.Info{Class: "Alacritty", Title: "vim main.go"}, -1},
+	}
+	for _, w := range windows {
+		if found := exampleMasks.Find(w.info); found != w.expected {
+			t.Fatalf("Expected mask %d for %+v, got %d", w.expected, w.info, found)
+		}
+	}
+}
+
+func TestMaskWithoutConditions(t *testing.T) {
+	if (Mask{Text: "nope"}).Matches(window.Info{Title: "anything"}) {
+		t.Fatal("Expected a mask without conditions to match nothing")
+	}
+}
+
+func TestMasksText(t *testing.T) {
+	if text, masked := exampleMasks.Text(window.Info{Class: "KeePassXC"}, "***"); !masked || text != "passwords" {
+		t.Fatalf("Expected the mask's own text, got %q", text)
+	}
+	if text, masked := exampleMasks.Text(window.Info{Class: "Slack", Title: "DM Bob"}, "***"); !masked || text != "***" {
+		t.Fatalf("Expected the default text, got %q", text)
+	}
+	if text, masked := exampleMasks.Text(window.Info{Class: "Slack", Title: "DM Bob"}, ""); !masked || text != DefaultMaskText {
+		t.Fatalf("Expected the fallback text, got %q", text)
+	}
+	if _, masked := exampleMasks.Text(window.Info{Class: "Slack", Title: "#general"}, "***"); masked {
+		t.Fatal("Expected no mask")
+	}
+}
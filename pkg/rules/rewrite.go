@@ -0,0 +1,86 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+// Rewrite replaces whatever its Pattern matches in a window title.
+type Rewrite struct {
+	// Class limits the rule to windows with this class or instance, ignoring
+	// case. An empty Class applies the rule to every window.
+	Class string
+
+	// Pattern is what gets replaced in the title.
+	Pattern *regexp.Regexp
+
+	// Replacement replaces every match of Pattern and can refer to capture
+	// groups like $1 or ${name}.
+	Replacement string
+}
+
+// Applies reports whether the rule applies to the given window.
+func (rewrite Rewrite) Applies(info window.Info) bool {
+	return rewrite.Class == "" ||
+		strings.EqualFold(rewrite.Class, info.Class) || strings.EqualFold(rewrite.Class, info.Instance)
+}
+
+// Rewrites are applied one after the other, each to the title the previous
+// one left behind.
+type Rewrites []Rewrite
+
+// RewriteStep records what a single Rewrite did to a title.
+type RewriteStep struct {
+	// Rule is the Rewrite that was tried.
+	Rule Rewrite
+
+	// Applied is false when the rule was skipped because of its Class.
+	Applied bool
+
+	// Title is the title after the rule, unchanged when it was skipped or
+	// nothing matched.
+	Title string
+}
+
+// Apply runs all rules over the title of the given window and returns the
+// result.
+func (rewrites Rewrites) Apply(info window.Info) string {
+	title := info.Title
+	for _, rewrite := range rewrites {
+		if rewrite.Applies(info) {
+			title = rewrite.Pattern.ReplaceAllString(title, rewrite.Replacement)
+		}
+	}
+	return title
+}
+
+// Trace runs all rules over the title of the given window like Apply does,
+// recording what each one did along the way.
+func (rewrites Rewrites) Trace(info window.Info) []RewriteStep {
+	title := info.Title
+	steps := make([]RewriteStep, 0, len(rewrites))
+	for _, rewrite := range rewrites {
+		applied := rewrite.Applies(info)
+		if applied {
+			title = rewrite.Pattern.ReplaceAllString(title, rewrite.Replacement)
+		}
+		steps = append(steps, RewriteStep{Rule: rewrite, Applied: applied, Title: title})
+	}
+	return steps
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/rholder/i3status-title-on-bar/pkg/window"
+)
+
+var exampleRewrites = Rewrites{
+	{Class: "firefox", Pattern: regexp.MustCompile(` — Mozilla Firefox$`)},
+	{Class: "code", Pattern: regexp.MustCompile(`^(.*) - (.*) - Visual Studio Code$`), Replacement: "$2: $1"},
+	{Pattern: regexp.MustCompile(`/home/ray`), Replacement: "~"},
+}
+
+func TestRewritesApply(t *testing.T) {
+	windows := map[string]window.Info{
+		"GitHub":                       {Class: "firefox", Title: "GitHub — Mozilla Firefox"},
+		"project: main.go":             {Class: "Code", Title: "main.go - project - Visual Studio Code"},
+		"vim ~/src":                    {Class: "Alacritty", Title: "vim /home/ray/src"},
+		"GitHub — Mozilla Firefox (1)": {Class: "firefox", Title: "GitHub — Mozilla Firefox (1)"},
+		"Slack — Mozilla Firefox":      {Class: "Slack", Title: "Slack — Mozilla Firefox"},
+	}
+	for expected, info := range windows {
+		if title := exampleRewrites.Apply(info); title != expected {
+			t.Fatalf("Expected %q for %+v, got %q", expected, info, title)
+		}
+	}
+}
+
+func TestRewritesApplyInstance(t *testing.T) {
+	info := window.Info{Class: "Firefox", Instance: "Navigator", Title: "x — Mozilla Firefox"}
+	rewrites := Rewrites{{Class: "navigator", Pattern: regexp.MustCompile(`^x`), Replacement: "y"}}
+	if title := rewrites.Apply(info); title != "y — Mozilla Firefox" {
+		t.Fatalf("Expected the instance to match, got %q", title)
+	}
+}
+
+func TestRewritesTrace(t *testing.T) {
+	steps := exampleRewrites.Trace(window.Info{Class: "firefox", Title: "/home/ray — Mozilla Firefox"})
+	if len(steps) != 3 {
+		t.Fatalf("Unexpected steps %+v", steps)
+	}
+	if !steps[0].Applied || steps[0].Title != "/home/ray" {
+		t.Fatalf("Unexpected first step %+v", steps[0])
+	}
+	if steps[1].Applied || steps[1].Title != "/home/ray" {
+		t.Fatalf("Expected second step to be skipped, got %+v", steps[1])
+	}
+	if !steps[2].Applied || steps[2].Title != "~" {
+		t.Fatalf("Unexpected last step %+v", steps[2])
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoFakeScript = errors.New("fake backend needs a script, set one with --fake-script")
+
+func init() {
+	// Never detected, this is only for demos and tests.
+	Register(Backend{
+		Name:     "fake",
+		Priority: 100,
+		Probe: func(options Options) (string, error) {
+			return "", errors.New("only used when asked for by name")
+		},
+		Open: func(options Options) (API, error) {
+			if options.FakeScript == "" {
+				return nil, ErrNoFakeScript
+			}
+			return NewFakeFromFile(options.FakeScript)
+		},
+	})
+}
+
+// Fake replays a script of window events instead of watching a real display.
+// Each line of a script is one step:
+//
+//	focus 1 Alacritty vim main.go   focus window 1 with a class and title
+//	retitle 1 vim i3.go             change the title of window 1
+//	close 1                         close window 1
+//	urgent 2 on                     make window 2 demand attention, or stop
+//	                                with off
+//	state 1 fullscreen sticky       set the states of window 1, or clear them
+//	workspace 2:web                 switch to a workspace by name
+//	idle 500ms                      wait before the next step
+//
+// Blank lines and lines starting with # are skipped. Window ids can be decimal
+// or hex with a 0x prefix.
+type Fake struct {
+	steps []fakeStep
+
+	mutex     sync.Mutex
+	windows   map[uint64]Info
+	active    uint64
+	workspace string
+}
+
+type fakeStep struct {
+	command string
+	id      uint64
+	class   string
+	title   string
+	urgent  bool
+	states  []string
+	idle    time.Duration
+}
+
+// NewFakeFromFile reads a Fake script from the given file.
+func NewFakeFromFile(path string) (*Fake, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewFake(file)
+}
+
+// NewFake reads a Fake script, failing on the first line that doesn't parse.
+// Nothing is active until DetectWindowTitleChanges starts replaying it.
+func NewFake(script io.Reader) (*Fake, error) {
+	fake := &Fake{windows: map[uint64]Info{}}
+	scanner := bufio.NewScanner(script)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		step, err := parseFakeStep(line)
+		if err != nil {
+			return nil, fmt.Errorf("fake script line %d: %w", lineNumber, err)
+		}
+		fake.steps = append(fake.steps, step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fake, nil
+}
+
+func parseFakeStep(line string) (fakeStep, error) {
+	fields := strings.Fields(line)
+	step := fakeStep{command: fields[0]}
+
+	var err error
+	switch step.command {
+	case "idle":
+		if len(fields) != 2 {
+			return step, errors.New("expected idle DURATION")
+		}
+		step.idle, err = time.ParseDuration(fields[1])
+		return step, err
+	case "focus":
+		if len(fields) < 3 {
+			return step, errors.New("expected focus ID CLASS [TITLE...]")
+		}
+		step.class = fields[2]
+		step.title = strings.Join(fields[3:], " ")
+	case "retitle":
+		if len(fields) < 2 {
+			return step, errors.New("expected retitle ID [TITLE...]")
+		}
+		step.title = strings.Join(fields[2:], " ")
+	case "close":
+		if len(fields) != 2 {
+			return step, errors.New("expected close ID")
+		}
+	case "urgent":
+		if len(fields) != 3 || (fields[2] != "on" && fields[2] != "off") {
+			return step, errors.New("expected urgent ID on|off")
+		}
+		step.urgent = fields[2] == "on"
+	case "state":
+		if len(fields) < 2 {
+			return step, errors.New("expected state ID [STATE...]")
+		}
+		step.states = fields[2:]
+	case "workspace":
+		if len(fields) < 2 {
+			return step, errors.New("expected workspace NAME")
+		}
+		step.title = strings.Join(fields[1:], " ")
+		return step, nil
+	default:
+		return step, fmt.Errorf("unknown command %q", step.command)
+	}
+
+	step.id, err = strconv.ParseUint(fields[1], 0, 64)
+	if err != nil {
+		return step, fmt.Errorf("bad window id %q", fields[1])
+	}
+	return step, nil
+}
+
+// ActiveWindowTitle returns the title of the window the script last focused or
+// an empty string if there is none.
+func (fake *Fake) ActiveWindowTitle() string {
+	info, err := fake.ActiveWindow()
+	if err != nil {
+		// no title on error
+		return ""
+	}
+	return info.Title
+}
+
+// ActiveWindow returns the window the script last focused.
+func (fake *Fake) ActiveWindow() (Info, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	info, found := fake.windows[fake.active]
+	if !found {
+		return Info{}, ErrNoActiveWindow
+	}
+	return info, nil
+}
+
+// UrgentWindows returns the windows the script made demand attention.
+func (fake *Fake) UrgentWindows() ([]Info, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	var urgent []Info
+	for _, info := range fake.windows {
+		if info.Urgent {
+			urgent = append(urgent, info)
+		}
+	}
+	sort.Slice(urgent, func(i, j int) bool { return urgent[i].ID < urgent[j].ID })
+	return urgent, nil
+}
+
+// CurrentWorkspace returns the workspace the script last switched to.
+func (fake *Fake) CurrentWorkspace() (string, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if fake.workspace == "" {
+		return "", ErrNoWorkspaces
+	}
+	return fake.workspace, nil
+}
+
+// DetectWindowTitleChanges replays the script, calling the onChange function
+// with an Event for every step that changes a window and returning once the
+// script runs out.
+func (fake *Fake) DetectWindowTitleChanges(onChange func(Event), onError func(error)) error {
+	for _, step := range fake.steps {
+		if step.command == "idle" {
+			time.Sleep(step.idle)
+			continue
+		}
+		onChange(fake.apply(step))
+	}
+	return nil
+}
+
+// Apply a single step to the current state and return the Event it causes.
+func (fake *Fake) apply(step fakeStep) Event {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	switch step.command {
+	case "focus":
+		fake.windows[step.id] = Info{ID: step.id, Title: step.title, Class: step.class}
+		fake.active = step.id
+		return Event{Type: FocusChanged, WindowID: step.id, Title: step.title}
+	case "retitle":
+		info := fake.windows[step.id]
+		info.ID, info.Title = step.id, step.title
+		fake.windows[step.id] = info
+		return Event{Type: TitleChanged, WindowID: step.id, Title: step.title}
+	case "urgent":
+		info := fake.windows[step.id]
+		info.ID, info.Urgent = step.id, step.urgent
+		fake.windows[step.id] = info
+		return Event{Type: UrgencyChanged, WindowID: step.id, Title: info.Title, Urgent: step.urgent}
+	case "state":
+		info := fake.windows[step.id]
+		info.ID, info.States = step.id, step.states
+		fake.windows[step.id] = info
+		return Event{Type: StateChanged, WindowID: step.id, Title: info.Title}
+	case "workspace":
+		fake.workspace = step.title
+		return Event{Type: WorkspaceChanged, Title: step.title}
+	default:
+		delete(fake.windows, step.id)
+		return Event{Type: WindowClosed, WindowID: step.id}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fakeScriptExample = `# a short demo
+focus 1 Alacritty vim main.go
+idle 10ms
+focus 0x2 firefox Mozilla Firefox
+retitle 2 GitHub - Mozilla Firefox
+close 2
+`
+
+func TestFakeReplay(t *testing.T) {
+	fake, err := NewFake(strings.NewReader(fakeScriptExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.ActiveWindow(); err != ErrNoActiveWindow {
+		t.Fatal("Expected nothing active before replay")
+	}
+
+	var events []Event
+	var titles []string
+	start := time.Now()
+	err = fake.DetectWindowTitleChanges(func(event Event) {
+		events = append(events, event)
+		titles = append(titles, fake.ActiveWindowTitle())
+	}, func(err error) {
+		t.Fatal(err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Fatal("Expected idle step to wait")
+	}
+
+	expected := []Event{
+		{Type: FocusChanged, WindowID: 1, Title: "vim main.go"},
+		{Type: FocusChanged, WindowID: 2, Title: "Mozilla Firefox"},
+		{Type: TitleChanged, WindowID: 2, Title: "GitHub - Mozilla Firefox"},
+		{Type: WindowClosed, WindowID: 2},
+	}
+	expectedTitles := []string{"vim main.go", "Mozilla Firefox", "GitHub - Mozilla Firefox", ""}
+	if len(events) != len(expected) {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("Expected %+v, got %+v", expected[i], events[i])
+		}
+		if titles[i] != expectedTitles[i] {
+			t.Fatalf("Expected title %q, got %q", expectedTitles[i], titles[i])
+		}
+	}
+}
+
+func TestFakeActiveWindowClass(t *testing.T) {
+	fake, err := NewFake(strings.NewReader("focus 7 Alacritty htop\nretitle 7 top"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.DetectWindowTitleChanges(func(Event) {}, func(error) {})
+
+	info, err := fake.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != 7 || info.Class != "Alacritty" || info.Title != "top" {
+		t.Fatalf("Unexpected window %+v", info)
+	}
+}
+
+func TestFakeBadScript(t *testing.T) {
+	scripts := map[string]string{
+		"focus 1":        "fake script line 1: expected focus ID CLASS [TITLE...]",
+		"\nidle potato":  `fake script line 2: time: invalid duration "potato"`,
+		"close x":        `fake script line 1: bad window id "x"`,
+		"urgent 1 maybe": "fake script line 1: expected urgent ID on|off",
+		"state":          "fake script line 1: expected state ID [STATE...]",
+		"dance 1":        `fake script line 1: unknown command "dance"`,
+	}
+	for script, expected := range scripts {
+		_, err := NewFake(strings.NewReader(script))
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Expected %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestFakeFromRegistry(t *testing.T) {
+	_, _, err := DefaultRegistry.Open("fake", Options{})
+	if err == nil {
+		t.Fatal("Expected error without a script")
+	}
+
+	path := filepath.Join(t.TempDir(), "demo.fake")
+	if err := os.WriteFile(path, []byte(fakeScriptExample), 0644); err != nil {
+		t.Fatal(err)
+	}
+	api, _, err := DefaultRegistry.Open("fake", Options{FakeScript: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := api.(*Fake); !ok {
+		t.Fatal("Expected a Fake")
+	}
+}
+
+func TestFakeWorkspace(t *testing.T) {
+	fake, err := NewFake(strings.NewReader("workspace 2: web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CurrentWorkspace(fake); err != ErrNoWorkspaces {
+		t.Fatal("Expected no workspace before replay")
+	}
+
+	var events []Event
+	fake.DetectWindowTitleChanges(func(event Event) {
+		events = append(events, event)
+	}, func(error) {})
+	if len(events) != 1 || events[0] != (Event{Type: WorkspaceChanged, Title: "2: web"}) {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	workspace, err := CurrentWorkspace(NewIgnoring(fake, IgnoreRules{}))
+	if err != nil || workspace != "2: web" {
+		t.Fatalf("Unexpected workspace %q, %v", workspace, err)
+	}
+}
+
+func TestFakeUrgentWindows(t *testing.T) {
+	fake, err := NewFake(strings.NewReader("focus 1 Alacritty vim\nfocus 2 Slack chat\nfocus 1 Alacritty vim\nurgent 2 on"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	err = fake.DetectWindowTitleChanges(func(event Event) {
+		events = append(events, event)
+	}, func(err error) {
+		t.Fatal(err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Event{Type: UrgencyChanged, WindowID: 2, Title: "chat", Urgent: true}
+	if events[len(events)-1] != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, events[len(events)-1])
+	}
+
+	urgent, err := UrgentWindows(fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urgent) != 1 || urgent[0].Title != "chat" {
+		t.Fatalf("Unexpected urgent windows %+v", urgent)
+	}
+
+	fake.apply(fakeStep{command: "urgent", id: 2})
+	if urgent, _ := UrgentWindows(fake); len(urgent) != 0 {
+		t.Fatalf("Expected no urgent windows, got %+v", urgent)
+	}
+}
+
+func TestFakeStates(t *testing.T) {
+	fake, err := NewFake(strings.NewReader("focus 1 mpv movie.mkv\nstate 1 fullscreen above"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	fake.DetectWindowTitleChanges(func(event Event) {
+		events = append(events, event)
+	}, func(err error) {
+		t.Fatal(err)
+	})
+	expected := Event{Type: StateChanged, WindowID: 1, Title: "movie.mkv"}
+	if len(events) != 2 || events[1] != expected {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	info, err := fake.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(info.States, " ") != "fullscreen above" {
+		t.Fatalf("Unexpected states %v", info.States)
+	}
+}
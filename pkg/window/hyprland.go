@@ -0,0 +1,258 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrNoHyprlandSocket = errors.New("Hyprland sockets could not be found, is HYPRLAND_INSTANCE_SIGNATURE set?")
+
+func init() {
+	Register(Backend{
+		Name:     "hyprland",
+		Priority: 20,
+		Probe:    probeEnv("HYPRLAND_INSTANCE_SIGNATURE"),
+		Open: func(options Options) (API, error) {
+			return newHyprland(options)
+		},
+	})
+}
+
+// Hyprland watches windows through the two sockets of a running Hyprland
+// instance. The request socket answers one command per connection and the
+// event socket streams one "name>>data" line per event.
+type Hyprland struct {
+	// This is the path to .socket.sock, used to query the current state.
+	RequestSocketPath string
+
+	// This is the path to .socket2.sock, used to receive events.
+	EventSocketPath string
+}
+
+// hyprlandWindow is the reply to the j/activewindow request, trimmed down to
+// the parts needed to describe a window.
+type hyprlandWindow struct {
+	Address  string `json:"address"`
+	Class    string `json:"class"`
+	Title    string `json:"title"`
+	PID      int    `json:"pid"`
+	Floating bool   `json:"floating"`
+	Pinned   bool   `json:"pinned"`
+	// This is a bool in older versions and a fullscreen mode number in newer
+	// ones, 0 meaning not fullscreen.
+	Fullscreen json.RawMessage `json:"fullscreen"`
+	Workspace  struct {
+		Name string `json:"name"`
+	} `json:"workspace"`
+}
+
+// NewHyprland finds the sockets of the running Hyprland instance from
+// HYPRLAND_INSTANCE_SIGNATURE, looking under XDG_RUNTIME_DIR first and /tmp
+// for older versions, and makes sure they accept connections.
+func NewHyprland() (*Hyprland, error) {
+	return newHyprland(Options{})
+}
+
+func newHyprland(options Options) (*Hyprland, error) {
+	signature := options.getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if signature == "" {
+		return nil, ErrNoHyprlandSocket
+	}
+
+	dirs := []string{filepath.Join("/tmp", "hypr", signature)}
+	if runtimeDir := options.getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		dirs = append([]string{filepath.Join(runtimeDir, "hypr", signature)}, dirs...)
+	}
+	for _, dir := range dirs {
+		requestSocketPath := filepath.Join(dir, ".socket.sock")
+		if _, err := os.Stat(requestSocketPath); err == nil {
+			return NewHyprlandForSockets(requestSocketPath, filepath.Join(dir, ".socket2.sock"))
+		}
+	}
+	return nil, ErrNoHyprlandSocket
+}
+
+// NewHyprlandForSockets creates a Hyprland for the given request and event
+// socket paths, making sure the request socket accepts connections.
+func NewHyprlandForSockets(requestSocketPath string, eventSocketPath string) (*Hyprland, error) {
+	conn, err := net.Dial("unix", requestSocketPath)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	return &Hyprland{RequestSocketPath: requestSocketPath, EventSocketPath: eventSocketPath}, nil
+}
+
+// ActiveWindowTitle returns the currently active window title or an empty
+// string if one is not available.
+func (hyprland Hyprland) ActiveWindowTitle() string {
+	info, err := hyprland.ActiveWindow()
+	if err != nil {
+		// no title on error
+		return ""
+	}
+	return info.Title
+}
+
+// ActiveWindow returns the title, class, pid, floating, fullscreen and pinned
+// state and workspace of the currently active window.
+func (hyprland Hyprland) ActiveWindow() (Info, error) {
+	var window hyprlandWindow
+	if err := hyprland.request("j/activewindow", &window); err != nil {
+		return Info{}, err
+	}
+	if window.Address == "" {
+		return Info{}, ErrNoActiveWindow
+	}
+	var states []string
+	if fullscreen := string(window.Fullscreen); fullscreen != "" && fullscreen != "false" && fullscreen != "0" {
+		states = append(states, "fullscreen")
+	}
+	if window.Pinned {
+		states = append(states, "sticky")
+	}
+	return Info{
+		ID:        parseHyprlandAddress(window.Address),
+		Title:     window.Title,
+		Class:     window.Class,
+		Floating:  window.Floating,
+		Workspace: window.Workspace.Name,
+		PID:       window.PID,
+		States:    states,
+	}, nil
+}
+
+// CurrentWorkspace returns the name of the active workspace.
+func (hyprland Hyprland) CurrentWorkspace() (string, error) {
+	var workspace struct {
+		Name string `json:"name"`
+	}
+	if err := hyprland.request("j/activeworkspace", &workspace); err != nil {
+		return "", err
+	}
+	if workspace.Name == "" {
+		return "", ErrNoWorkspaces
+	}
+	return workspace.Name, nil
+}
+
+// Send a single command to the request socket and decode the JSON reply into
+// the given value.
+func (hyprland Hyprland) request(command string, reply interface{}) error {
+	conn, err := net.Dial("unix", hyprland.RequestSocketPath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(command)); err != nil {
+		return err
+	}
+	// the reply ends when Hyprland closes the connection
+	payload, err := io.ReadAll(conn)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, reply)
+}
+
+// DetectWindowTitleChanges blocks and starts detecting changes in window
+// titles by reading the event socket. When a change is detected, the onChange
+// function is called with an Event describing it and when a non-fatal error
+// occurs the onError function is called for that error.
+func (hyprland Hyprland) DetectWindowTitleChanges(onChange func(Event), onError func(error)) error {
+	conn, err := net.Dial("unix", hyprland.EventSocketPath)
+	if err != nil {
+		onError(err)
+		return err
+	}
+	defer conn.Close()
+
+	// Focus changes arrive as activewindow>>class,title immediately followed
+	// by activewindowv2>>address, so hold on to the title until the address
+	// shows up.
+	var activeAddress uint64
+	var pendingTitle string
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		name, data, found := strings.Cut(scanner.Text(), ">>")
+		if !found {
+			continue
+		}
+
+		switch name {
+		case "activewindow":
+			// the class never contains a comma but the title might
+			_, pendingTitle, _ = strings.Cut(data, ",")
+		case "activewindowv2":
+			activeAddress = parseHyprlandAddress(data)
+			onChange(Event{Type: FocusChanged, WindowID: activeAddress, Title: pendingTitle})
+		case "windowtitlev2":
+			address, title, _ := strings.Cut(data, ",")
+			onChange(Event{Type: TitleChanged, WindowID: parseHyprlandAddress(address), Title: title})
+		case "windowtitle":
+			// Older versions only send the address, so the title has to be
+			// looked up. Only the active window's title is visible anyway.
+			if address := parseHyprlandAddress(data); address == activeAddress {
+				info, err := hyprland.ActiveWindow()
+				if err != nil {
+					onError(err)
+					continue
+				}
+				onChange(Event{Type: TitleChanged, WindowID: info.ID, Title: info.Title})
+			}
+		case "closewindow":
+			onChange(Event{Type: WindowClosed, WindowID: parseHyprlandAddress(data)})
+		case "workspace":
+			onChange(Event{Type: WorkspaceChanged, Title: data})
+		case "fullscreen":
+			// this is always about the active window
+			onChange(Event{Type: StateChanged, WindowID: activeAddress})
+		case "changefloatingmode", "pin":
+			address, _, _ := strings.Cut(data, ",")
+			onChange(Event{Type: StateChanged, WindowID: parseHyprlandAddress(address)})
+		default:
+			// Ignore everything else.
+		}
+	}
+
+	// The event socket only ends when Hyprland goes away.
+	err = scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	onError(err)
+	return err
+}
+
+// Parse a window address, which is hex with a 0x prefix in replies and without
+// one in events. Returns 0 when it can't be parsed.
+func parseHyprlandAddress(address string) uint64 {
+	id, err := strconv.ParseUint(strings.TrimPrefix(address, "0x"), 16, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
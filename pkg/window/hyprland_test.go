@@ -0,0 +1,213 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const hyprlandWindowExample = `{"address":"0x55d0c0ffee10","class":"kitty","title":"htop","pid":2048,
+	"floating":true,"pinned":true,"fullscreen":2,"workspace":{"id":2,"name":"code"}}`
+
+// Start a fake Hyprland with a request socket that answers every request with
+// the given reply and an event socket that sends the given lines and hangs up.
+func newFakeHyprland(t *testing.T, reply string, lines ...string) *Hyprland {
+	dir := t.TempDir()
+	requestSocketPath := filepath.Join(dir, ".socket.sock")
+	eventSocketPath := filepath.Join(dir, ".socket2.sock")
+
+	requestListener, err := net.Listen("unix", requestSocketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { requestListener.Close() })
+	go func() {
+		for {
+			conn, err := requestListener.Accept()
+			if err != nil {
+				return
+			}
+			request := make([]byte, 64)
+			n, _ := conn.Read(request)
+			if n > 0 {
+				io.WriteString(conn, reply)
+			}
+			conn.Close()
+		}
+	}()
+
+	eventListener, err := net.Listen("unix", eventSocketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { eventListener.Close() })
+	go func() {
+		conn, err := eventListener.Accept()
+		if err != nil {
+			return
+		}
+		for _, line := range lines {
+			io.WriteString(conn, line+"\n")
+		}
+		conn.Close()
+	}()
+
+	hyprland, err := NewHyprlandForSockets(requestSocketPath, eventSocketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hyprland
+}
+
+func TestHyprlandActiveWindow(t *testing.T) {
+	api := newFakeHyprland(t, hyprlandWindowExample)
+
+	info, err := api.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != 0x55d0c0ffee10 || info.Title != "htop" || info.Class != "kitty" || info.PID != 2048 {
+		t.Fatalf("Unexpected window %+v", info)
+	}
+	if !info.Floating || info.Workspace != "code" {
+		t.Fatalf("Unexpected window %+v", info)
+	}
+	if len(info.States) != 2 || info.States[0] != "fullscreen" || info.States[1] != "sticky" {
+		t.Fatalf("Unexpected states %v", info.States)
+	}
+	if api.ActiveWindowTitle() != "htop" {
+		t.Fatal("Unexpected title")
+	}
+}
+
+func TestHyprlandOlderFullscreen(t *testing.T) {
+	api := newFakeHyprland(t, `{"address":"0x1","title":"htop","fullscreen":false}`)
+
+	info, err := api.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.States) != 0 {
+		t.Fatalf("Unexpected states %v", info.States)
+	}
+}
+
+func TestHyprlandNoActiveWindow(t *testing.T) {
+	api := newFakeHyprland(t, "{}")
+
+	_, err := api.ActiveWindow()
+	if err != ErrNoActiveWindow {
+		t.Fatalf("Expected no active window, got %v", err)
+	}
+	if api.ActiveWindowTitle() != "" {
+		t.Fatal("Expected empty title")
+	}
+}
+
+func TestHyprlandMissingSocket(t *testing.T) {
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+	_, err := NewHyprland()
+	if err != ErrNoHyprlandSocket {
+		t.Fatalf("Expected missing socket error, got %v", err)
+	}
+}
+
+func TestHyprlandSocketsFromOptions(t *testing.T) {
+	runtimeDir := t.TempDir()
+	dir := filepath.Join(runtimeDir, "hypr", "c0ffee")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("unix", filepath.Join(dir, ".socket.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+	backend, _ := DefaultRegistry.Lookup("hyprland")
+	api, err := backend.Open(testEnv(map[string]string{
+		"HYPRLAND_INSTANCE_SIGNATURE": "c0ffee",
+		"XDG_RUNTIME_DIR":             runtimeDir,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.(*Hyprland).EventSocketPath != filepath.Join(dir, ".socket2.sock") {
+		t.Fatalf("Unexpected sockets %+v", api)
+	}
+}
+
+func TestHyprlandDetectWindowTitleChanges(t *testing.T) {
+	api := newFakeHyprland(t, hyprlandWindowExample,
+		"workspace>>code",
+		"activewindow>>kitty,htop, the sequel",
+		"activewindowv2>>55d0c0ffee10",
+		"windowtitlev2>>55d0c0ffee10,top",
+		"windowtitle>>55d0c0ffee10",
+		"windowtitle>>1234",
+		"fullscreen>>1",
+		"pin>>1234,1",
+		"closewindow>>55d0c0ffee10",
+		"POTATO")
+
+	var events []Event
+	var errs []error
+	err := api.DetectWindowTitleChanges(func(event Event) {
+		events = append(events, event)
+	}, func(err error) {
+		errs = append(errs, err)
+	})
+	if err != io.EOF {
+		t.Fatalf("Expected EOF when the connection closes, got %v", err)
+	}
+
+	expected := []Event{
+		{Type: WorkspaceChanged, Title: "code"},
+		{Type: FocusChanged, WindowID: 0x55d0c0ffee10, Title: "htop, the sequel"},
+		{Type: TitleChanged, WindowID: 0x55d0c0ffee10, Title: "top"},
+		{Type: TitleChanged, WindowID: 0x55d0c0ffee10, Title: "htop"},
+		{Type: StateChanged, WindowID: 0x55d0c0ffee10},
+		{Type: StateChanged, WindowID: 0x1234},
+		{Type: WindowClosed, WindowID: 0x55d0c0ffee10},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("Expected %+v, got %+v", expected[i], events[i])
+		}
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Unexpected errors %v", errs)
+	}
+}
+
+func TestHyprlandCurrentWorkspace(t *testing.T) {
+	api := newFakeHyprland(t, `{"id":2,"name":"code"}`)
+
+	workspace, err := CurrentWorkspace(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workspace != "code" {
+		t.Fatalf("Unexpected workspace %s", workspace)
+	}
+}
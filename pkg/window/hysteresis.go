@@ -0,0 +1,136 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"sync"
+	"time"
+)
+
+// Hysteresis wraps an API so that a newly focused window is only reported once
+// it has stayed focused for a minimum dwell time. Cycling through windows
+// quickly then only ever shows the window that was landed on, while title
+// changes of the window being shown still apply immediately.
+type Hysteresis struct {
+	api   API
+	dwell time.Duration
+
+	mutex        sync.Mutex
+	shown        *Info
+	pendingID    uint64
+	pendingSince time.Time
+	timer        *time.Timer
+}
+
+// NewHysteresis wraps the given API with the given dwell time.
+func NewHysteresis(api API, dwell time.Duration) *Hysteresis {
+	return &Hysteresis{api: api, dwell: dwell}
+}
+
+// ActiveWindowTitle returns the title of the active window once it has dwelled
+// long enough, or an empty string if no window is available.
+func (hysteresis *Hysteresis) ActiveWindowTitle() string {
+	info, err := hysteresis.ActiveWindow()
+	if err != nil {
+		// no title on error
+		return ""
+	}
+	return info.Title
+}
+
+// ActiveWindow returns the active window once it has dwelled long enough,
+// otherwise the window that was shown before it.
+func (hysteresis *Hysteresis) ActiveWindow() (Info, error) {
+	info, err := hysteresis.api.ActiveWindow()
+	if err != nil {
+		return info, err
+	}
+
+	hysteresis.mutex.Lock()
+	defer hysteresis.mutex.Unlock()
+	if hysteresis.shown != nil && hysteresis.shown.ID != info.ID && !hysteresis.dwelled(info.ID) {
+		return *hysteresis.shown, nil
+	}
+	hysteresis.shown = &info
+	return info, nil
+}
+
+// Report whether the given window has been the focus candidate for at least
+// the dwell time, making it the candidate if it wasn't already. Must be called
+// with the mutex held.
+func (hysteresis *Hysteresis) dwelled(id uint64) bool {
+	if hysteresis.pendingID != id || hysteresis.pendingSince.IsZero() {
+		hysteresis.pendingID, hysteresis.pendingSince = id, time.Now()
+	}
+	return time.Since(hysteresis.pendingSince) >= hysteresis.dwell
+}
+
+// CurrentWorkspace returns the current workspace of the wrapped API.
+func (hysteresis *Hysteresis) CurrentWorkspace() (string, error) {
+	return CurrentWorkspace(hysteresis.api)
+}
+
+// UrgentWindows returns the windows of the wrapped API demanding attention.
+func (hysteresis *Hysteresis) UrgentWindows() ([]Info, error) {
+	return UrgentWindows(hysteresis.api)
+}
+
+// DetectWindowTitleChanges passes on the changes detected by the wrapped API,
+// holding back focus changes until the newly focused window has dwelled long
+// enough and dropping them if focus moved on in the meantime.
+func (hysteresis *Hysteresis) DetectWindowTitleChanges(onChange func(Event), onError func(error)) error {
+	// Delayed focus changes come from timer goroutines, so make sure onChange
+	// is only ever called by one goroutine at a time.
+	var onChangeMutex sync.Mutex
+	safeOnChange := func(event Event) {
+		onChangeMutex.Lock()
+		defer onChangeMutex.Unlock()
+		onChange(event)
+	}
+
+	return hysteresis.api.DetectWindowTitleChanges(func(event Event) {
+		if event.Type != FocusChanged || !hysteresis.holdFocus(event, safeOnChange) {
+			safeOnChange(event)
+		}
+	}, onError)
+}
+
+// Report whether the given focus change has to be held back, which is the case
+// unless it returns to the window being shown. A held back change is sent to
+// the given onChange function later, once the window has dwelled long enough
+// and if nothing else was focused in the meantime.
+func (hysteresis *Hysteresis) holdFocus(event Event, onChange func(Event)) bool {
+	hysteresis.mutex.Lock()
+	defer hysteresis.mutex.Unlock()
+
+	if hysteresis.timer != nil {
+		hysteresis.timer.Stop()
+	}
+	if hysteresis.shown == nil || hysteresis.shown.ID == event.WindowID {
+		hysteresis.pendingID, hysteresis.pendingSince = event.WindowID, time.Time{}
+		return false
+	}
+
+	hysteresis.dwelled(event.WindowID)
+	hysteresis.timer = time.AfterFunc(hysteresis.dwell, func() {
+		hysteresis.mutex.Lock()
+		stillPending := hysteresis.pendingID == event.WindowID
+		hysteresis.mutex.Unlock()
+		if stillPending {
+			onChange(event)
+		}
+	})
+	return true
+}
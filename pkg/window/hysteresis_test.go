@@ -0,0 +1,93 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHysteresisSuppressesFlicker(t *testing.T) {
+	script := `focus 1 Alacritty one
+focus 2 firefox two
+idle 5ms
+focus 3 Slack three
+idle 5ms
+focus 1 Alacritty one
+retitle 1 one!
+focus 2 firefox two
+idle 100ms`
+	fake, err := NewFake(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hysteresis := NewHysteresis(fake, 30*time.Millisecond)
+
+	var mutex sync.Mutex
+	var events []Event
+	var titles []string
+	hysteresis.DetectWindowTitleChanges(func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event)
+		titles = append(titles, hysteresis.ActiveWindowTitle())
+	}, func(err error) {
+		t.Fatal(err)
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	expected := []Event{
+		{Type: FocusChanged, WindowID: 1, Title: "one"},
+		{Type: FocusChanged, WindowID: 1, Title: "one"},
+		{Type: TitleChanged, WindowID: 1, Title: "one!"},
+		{Type: FocusChanged, WindowID: 2, Title: "two"},
+	}
+	expectedTitles := []string{"one", "one", "one!", "two"}
+	if len(events) != len(expected) {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("Expected %+v, got %+v", expected[i], events[i])
+		}
+		if titles[i] != expectedTitles[i] {
+			t.Fatalf("Expected title %q, got %q", expectedTitles[i], titles[i])
+		}
+	}
+}
+
+func TestHysteresisPollingHoldsNewWindow(t *testing.T) {
+	fake, err := NewFake(strings.NewReader("focus 1 Alacritty one\nfocus 2 firefox two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hysteresis := NewHysteresis(fake, 20*time.Millisecond)
+
+	fake.apply(fake.steps[0])
+	if hysteresis.ActiveWindowTitle() != "one" {
+		t.Fatal("Expected first window to show right away")
+	}
+	fake.apply(fake.steps[1])
+	if hysteresis.ActiveWindowTitle() != "one" {
+		t.Fatal("Expected new window to be held back")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if hysteresis.ActiveWindowTitle() != "two" {
+		t.Fatal("Expected new window to show after dwelling")
+	}
+}
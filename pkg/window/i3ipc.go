@@ -0,0 +1,235 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+var ErrNoI3Socket = errors.New("i3 IPC socket could not be found, is I3SOCK set or i3 in the PATH?")
+
+func init() {
+	// X11 sees everything i3 manages, so this is only picked without a display.
+	Register(Backend{
+		Name:     "i3ipc",
+		Priority: 40,
+		Probe: func(options Options) (string, error) {
+			if socketPath := options.getenv("I3SOCK"); socketPath != "" {
+				return "I3SOCK is set to " + socketPath, nil
+			}
+			if _, err := options.lookPath("i3"); err != nil {
+				return "", ErrNoI3Socket
+			}
+			return "i3 is in the PATH", nil
+		},
+		Open: func(options Options) (API, error) {
+			return newI3IPC(options)
+		},
+	})
+}
+
+// I3IPC watches windows through the IPC socket of a running i3 instance
+// instead of X11 properties.
+type I3IPC struct {
+	// This is the path to the i3 IPC unix socket.
+	SocketPath string
+}
+
+// NewI3IPC finds the socket of the running i3 instance, first from I3SOCK and
+// then by asking i3 itself, and makes sure it accepts connections.
+func NewI3IPC() (*I3IPC, error) {
+	return newI3IPC(Options{})
+}
+
+func newI3IPC(options Options) (*I3IPC, error) {
+	socketPath, err := findSocket(options, "I3SOCK", "i3")
+	if err != nil {
+		return nil, ErrNoI3Socket
+	}
+	return NewI3IPCForSocket(socketPath)
+}
+
+// Find the IPC socket from the given environment variable, or else by asking
+// the given command, i3 or sway, for it.
+func findSocket(options Options, key string, command string) (string, error) {
+	if socketPath := options.getenv(key); socketPath != "" {
+		return socketPath, nil
+	}
+	path, err := options.lookPath(command)
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command(path, "--get-socketpath").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// NewI3IPCForSocket creates an I3IPC for the given socket path, making sure it
+// accepts connections.
+func NewI3IPCForSocket(socketPath string) (*I3IPC, error) {
+	if socketPath == "" {
+		return nil, ErrNoI3Socket
+	}
+	conn, err := dialIPC(socketPath)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	return &I3IPC{SocketPath: socketPath}, nil
+}
+
+// ActiveWindowTitle returns the currently focused window title or an empty
+// string if one is not available.
+func (i3 I3IPC) ActiveWindowTitle() string {
+	info, err := i3.ActiveWindow()
+	if err != nil {
+		// no title on error
+		return ""
+	}
+	return info.Title
+}
+
+// ActiveWindow returns the title, class, marks, floating, fullscreen and sticky
+// state, urgency and workspace of the currently focused container.
+func (i3 I3IPC) ActiveWindow() (Info, error) {
+	conn, err := dialIPC(i3.SocketPath)
+	if err != nil {
+		return Info{}, err
+	}
+	defer conn.Close()
+
+	root, err := conn.tree()
+	if err != nil {
+		return Info{}, err
+	}
+	node, workspace := focusedNode(root, "")
+	if node == nil {
+		return Info{}, ErrNoActiveWindow
+	}
+	return node.info(workspace), nil
+}
+
+// UrgentWindows returns every window in the tree demanding attention.
+func (i3 I3IPC) UrgentWindows() ([]Info, error) {
+	conn, err := dialIPC(i3.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	root, err := conn.tree()
+	if err != nil {
+		return nil, err
+	}
+	return urgentNodes(root, "", nil), nil
+}
+
+// CurrentWorkspace returns the name of the focused workspace.
+func (i3 I3IPC) CurrentWorkspace() (string, error) {
+	conn, err := dialIPC(i3.SocketPath)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	workspaces, err := conn.workspaces()
+	if err != nil {
+		return "", err
+	}
+	for _, workspace := range workspaces {
+		if workspace.Focused {
+			return workspace.Name, nil
+		}
+	}
+	return "", ErrNoWorkspaces
+}
+
+// DetectWindowTitleChanges blocks and starts detecting changes in window
+// titles by subscribing to window and workspace events. When a change is
+// detected, the onChange function is called with an Event describing it and
+// when a non-fatal error occurs the onError function is called for that error.
+func (i3 I3IPC) DetectWindowTitleChanges(onChange func(Event), onError func(error)) error {
+	conn, err := dialIPC(i3.SocketPath)
+	if err != nil {
+		onError(err)
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.subscribe("window", "workspace"); err != nil {
+		onError(err)
+		return err
+	}
+
+	for {
+		// A broken connection means i3 went away, nothing else will arrive.
+		eventType, payload, err := conn.receive()
+		if err != nil {
+			onError(err)
+			return err
+		}
+
+		switch eventType {
+		case ipcEventWindow:
+			var windowEvent ipcWindowEvent
+			if err := json.Unmarshal(payload, &windowEvent); err != nil {
+				onError(err)
+				continue
+			}
+			event := Event{WindowID: uint64(windowEvent.Container.ID), Title: windowEvent.Container.Name}
+			switch windowEvent.Change {
+			case "focus":
+				event.Type = FocusChanged
+			case "title":
+				event.Type = TitleChanged
+			case "close":
+				event.Type, event.Title = WindowClosed, ""
+			case "urgent":
+				event.Type, event.Urgent = UrgencyChanged, windowEvent.Container.Urgent
+			case "fullscreen_mode", "floating":
+				event.Type = StateChanged
+			default:
+				// Ignore moves, marks, new windows and the like.
+				continue
+			}
+			onChange(event)
+		case ipcEventWorkspace:
+			var workspaceEvent ipcWorkspaceEvent
+			if err := json.Unmarshal(payload, &workspaceEvent); err != nil {
+				onError(err)
+				continue
+			}
+			if workspaceEvent.Current != nil && (workspaceEvent.Change == "focus" || workspaceEvent.Change == "rename") {
+				onChange(Event{Type: WorkspaceChanged, Title: workspaceEvent.Current.Name})
+			}
+			if workspaceEvent.Change != "focus" {
+				continue
+			}
+			// Switching to an empty workspace sends no window event, so look
+			// up whatever has focus now.
+			info, err := i3.ActiveWindow()
+			if err != nil && err != ErrNoActiveWindow {
+				onError(err)
+				continue
+			}
+			onChange(Event{Type: FocusChanged, WindowID: info.ID, Title: info.Title})
+		}
+	}
+}
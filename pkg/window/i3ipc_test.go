@@ -0,0 +1,185 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"testing"
+)
+
+const i3TreeExample = `{"id":1,"type":"root","nodes":[
+	{"id":2,"type":"output","name":"DP-1","nodes":[
+		{"id":3,"type":"workspace","name":"2:web","nodes":[
+			{"id":4,"type":"con","name":"vim main.go","urgent":true,"marks":["edit"],"floating":"auto_off",
+				"window_properties":{"class":"Alacritty","instance":"alacritty","title":"vim main.go"}}
+		],"floating_nodes":[
+			{"id":5,"type":"floating_con","floating":"user_on","nodes":[
+				{"id":6,"type":"con","name":"Mozilla Firefox","focused":true,"floating":"user_on","sticky":true,
+					"window_properties":{"class":"firefox","instance":"Navigator","title":"Mozilla Firefox"}}
+			]}
+		]}
+	]}
+]}`
+
+const i3EmptyTreeExample = `{"id":1,"type":"root","nodes":[
+	{"id":2,"type":"output","name":"DP-1","nodes":[
+		{"id":3,"type":"workspace","name":"1","focused":true}
+	]}
+]}`
+
+func TestI3IPCActiveWindow(t *testing.T) {
+	server := newFakeIPCServer(t, i3TreeExample)
+	api, err := NewI3IPCForSocket(server.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := api.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != 6 || info.Title != "Mozilla Firefox" || info.Class != "firefox" || info.Instance != "Navigator" {
+		t.Fatalf("Unexpected window %+v", info)
+	}
+	if !info.Floating {
+		t.Fatal("Expected floating window")
+	}
+	if len(info.States) != 1 || info.States[0] != "sticky" {
+		t.Fatalf("Unexpected states %v", info.States)
+	}
+	if info.Workspace != "2:web" {
+		t.Fatalf("Unexpected workspace %s", info.Workspace)
+	}
+	if api.ActiveWindowTitle() != "Mozilla Firefox" {
+		t.Fatal("Unexpected title")
+	}
+}
+
+func TestI3IPCNoActiveWindow(t *testing.T) {
+	server := newFakeIPCServer(t, i3EmptyTreeExample)
+	api, err := NewI3IPCForSocket(server.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = api.ActiveWindow()
+	if err != ErrNoActiveWindow {
+		t.Fatalf("Expected no active window, got %v", err)
+	}
+	if api.ActiveWindowTitle() != "" {
+		t.Fatal("Expected empty title")
+	}
+}
+
+func TestI3IPCUrgentWindows(t *testing.T) {
+	server := newFakeIPCServer(t, i3TreeExample)
+	api, err := NewI3IPCForSocket(server.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urgent, err := api.UrgentWindows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urgent) != 1 || urgent[0].ID != 4 || urgent[0].Title != "vim main.go" || urgent[0].Workspace != "2:web" {
+		t.Fatalf("Unexpected urgent windows %+v", urgent)
+	}
+
+	info, err := api.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Urgent {
+		t.Fatal("Expected the focused window to be calm")
+	}
+}
+
+func TestI3IPCMissingSocket(t *testing.T) {
+	_, err := NewI3IPCForSocket("")
+	if err != ErrNoI3Socket {
+		t.Fatalf("Expected missing socket error, got %v", err)
+	}
+	_, err = NewI3IPCForSocket("/nonexistent/i3.sock")
+	if err == nil {
+		t.Fatal("Expected dial error")
+	}
+}
+
+func TestI3IPCDetectWindowTitleChanges(t *testing.T) {
+	server := newFakeIPCServer(t, i3EmptyTreeExample,
+		fakeIPCEvent{ipcEventWindow, `{"change":"focus","container":{"id":4,"name":"vim main.go"}}`},
+		fakeIPCEvent{ipcEventWindow, `{"change":"mark","container":{"id":4,"name":"vim main.go"}}`},
+		fakeIPCEvent{ipcEventWindow, `{"change":"title","container":{"id":4,"name":"vim i3.go"}}`},
+		fakeIPCEvent{ipcEventWindow, `{"change":"urgent","container":{"id":4,"name":"vim i3.go","urgent":true}}`},
+		fakeIPCEvent{ipcEventWindow, `{"change":"fullscreen_mode","container":{"id":4,"name":"vim i3.go","fullscreen_mode":1}}`},
+		fakeIPCEvent{ipcEventWindow, `{"change":"close","container":{"id":4,"name":"vim i3.go"}}`},
+		fakeIPCEvent{ipcEventWorkspace, `{"change":"focus","current":{"name":"1"}}`},
+		fakeIPCEvent{ipcEventWorkspace, `{"change":"init","current":{"name":"4"}}`},
+		fakeIPCEvent{ipcEventWindow, `POTATO`})
+	api, err := NewI3IPCForSocket(server.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []Event
+	var errs []error
+	err = api.DetectWindowTitleChanges(func(event Event) {
+		events = append(events, event)
+	}, func(err error) {
+		errs = append(errs, err)
+	})
+	if err == nil {
+		t.Fatal("Expected error when the connection closes")
+	}
+
+	expected := []Event{
+		{Type: FocusChanged, WindowID: 4, Title: "vim main.go"},
+		{Type: TitleChanged, WindowID: 4, Title: "vim i3.go"},
+		{Type: UrgencyChanged, WindowID: 4, Title: "vim i3.go", Urgent: true},
+		{Type: StateChanged, WindowID: 4, Title: "vim i3.go"},
+		{Type: WindowClosed, WindowID: 4},
+		{Type: WorkspaceChanged, Title: "1"},
+		{Type: FocusChanged},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("Expected %+v, got %+v", expected[i], events[i])
+		}
+	}
+	// bad JSON and the closed connection
+	if len(errs) != 2 {
+		t.Fatalf("Unexpected errors %v", errs)
+	}
+}
+
+func TestI3IPCCurrentWorkspace(t *testing.T) {
+	server := newFakeIPCServer(t, i3EmptyTreeExample)
+	server.workspaces = `[{"name":"1","focused":false},{"name":"2:web","focused":true}]`
+	api, err := NewI3IPCForSocket(server.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workspace, err := CurrentWorkspace(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workspace != "2:web" {
+		t.Fatalf("Unexpected workspace %s", workspace)
+	}
+}
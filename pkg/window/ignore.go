@@ -0,0 +1,186 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IgnoreRules match windows that should never be reported as active, like
+// launchers, notifications and tooltips that only grab focus for a moment. A
+// window is ignored when any single rule matches it.
+type IgnoreRules struct {
+	// Types are window types, like "dialog" or "notification".
+	Types []string
+
+	// Classes are matched against both the class and instance of a window,
+	// ignoring case.
+	Classes []string
+
+	// Roles are window roles, like "pop-up".
+	Roles []string
+
+	// Titles are patterns matched against the window title.
+	Titles []*regexp.Regexp
+}
+
+// Empty reports whether there are no rules at all.
+func (rules IgnoreRules) Empty() bool {
+	return len(rules.Types) == 0 && len(rules.Classes) == 0 && len(rules.Roles) == 0 && len(rules.Titles) == 0
+}
+
+// Match reports whether the given window should be ignored.
+func (rules IgnoreRules) Match(info Info) bool {
+	for _, windowType := range info.Types {
+		if containsFold(rules.Types, windowType) {
+			return true
+		}
+	}
+	if containsFold(rules.Classes, info.Class) || containsFold(rules.Classes, info.Instance) {
+		return true
+	}
+	if containsFold(rules.Roles, info.Role) {
+		return true
+	}
+	for _, title := range rules.Titles {
+		if title.MatchString(info.Title) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignoring wraps an API so that whenever an ignored window is active, the last
+// window that wasn't ignored keeps being reported instead.
+type Ignoring struct {
+	api   API
+	rules IgnoreRules
+
+	mutex sync.Mutex
+	last  *Info
+}
+
+// NewIgnoring wraps the given API with the given rules.
+func NewIgnoring(api API, rules IgnoreRules) *Ignoring {
+	return &Ignoring{api: api, rules: rules}
+}
+
+// ActiveWindowTitle returns the title of the active window or of the last one
+// that wasn't ignored, or an empty string if neither is available.
+func (ignoring *Ignoring) ActiveWindowTitle() string {
+	info, err := ignoring.ActiveWindow()
+	if err != nil {
+		// no title on error
+		return ""
+	}
+	return info.Title
+}
+
+// ActiveWindow returns the active window, or the last one that wasn't ignored
+// when the active window is.
+func (ignoring *Ignoring) ActiveWindow() (Info, error) {
+	info, err := ignoring.api.ActiveWindow()
+	if err != nil {
+		return info, err
+	}
+
+	ignoring.mutex.Lock()
+	defer ignoring.mutex.Unlock()
+	if ignoring.rules.Match(info) {
+		if ignoring.last == nil {
+			return Info{}, ErrNoActiveWindow
+		}
+		return *ignoring.last, nil
+	}
+	ignoring.last = &info
+	return info, nil
+}
+
+// CurrentWorkspace returns the current workspace of the wrapped API.
+func (ignoring *Ignoring) CurrentWorkspace() (string, error) {
+	return CurrentWorkspace(ignoring.api)
+}
+
+// UrgentWindows returns the windows of the wrapped API demanding attention,
+// leaving out ignored ones.
+func (ignoring *Ignoring) UrgentWindows() ([]Info, error) {
+	urgent, err := UrgentWindows(ignoring.api)
+	if err != nil {
+		return nil, err
+	}
+	var kept []Info
+	for _, info := range urgent {
+		if !ignoring.rules.Match(info) {
+			kept = append(kept, info)
+		}
+	}
+	return kept, nil
+}
+
+// DetectWindowTitleChanges passes on the changes detected by the wrapped API,
+// dropping those that are about an ignored window. The active window is only
+// looked up on focus changes, later changes are compared to its id.
+func (ignoring *Ignoring) DetectWindowTitleChanges(onChange func(Event), onError func(error)) error {
+	var ignoredID uint64
+	return ignoring.api.DetectWindowTitleChanges(func(event Event) {
+		switch event.Type {
+		case FocusChanged:
+			ignoredID = 0
+			info, err := ignoring.api.ActiveWindow()
+			if err == nil && event.WindowID != 0 && info.ID == event.WindowID && ignoring.rules.Match(info) {
+				ignoredID = event.WindowID
+				return
+			}
+		case WindowClosed:
+			if event.WindowID == ignoredID {
+				ignoredID = 0
+			}
+		default:
+			if ignoredID != 0 && event.WindowID == ignoredID {
+				return
+			}
+		}
+		if event.Type == TitleChanged {
+			ignoring.retitle(event)
+		}
+		onChange(event)
+	}, onError)
+}
+
+// Keep the title of the remembered window current while it sits behind an
+// ignored one.
+func (ignoring *Ignoring) retitle(event Event) {
+	ignoring.mutex.Lock()
+	defer ignoring.mutex.Unlock()
+	if ignoring.last != nil && ignoring.last.ID == event.WindowID {
+		last := *ignoring.last
+		last.Title = event.Title
+		ignoring.last = &last
+	}
+}
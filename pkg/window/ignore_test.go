@@ -0,0 +1,158 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var ignoreRulesExample = IgnoreRules{
+	Types:   []string{"notification", "dock"},
+	Classes: []string{"Rofi"},
+	Roles:   []string{"pop-up"},
+	Titles:  []*regexp.Regexp{regexp.MustCompile(`^Picture-in-Picture$`)},
+}
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	ignored := []Info{
+		{Types: []string{"normal", "notification"}},
+		{Class: "rofi"},
+		{Instance: "ROFI"},
+		{Role: "pop-up"},
+		{Title: "Picture-in-Picture"},
+	}
+	for _, info := range ignored {
+		if !ignoreRulesExample.Match(info) {
+			t.Fatalf("Expected %+v to be ignored", info)
+		}
+	}
+
+	kept := []Info{
+		{},
+		{Types: []string{"normal"}, Class: "Alacritty", Role: "browser", Title: "Picture-in-Picture mode"},
+	}
+	for _, info := range kept {
+		if ignoreRulesExample.Match(info) {
+			t.Fatalf("Expected %+v to be kept", info)
+		}
+	}
+
+	if ignoreRulesExample.Empty() || !(IgnoreRules{}).Empty() {
+		t.Fatal("Unexpected emptiness")
+	}
+}
+
+func TestIgnoringKeepsLastWindow(t *testing.T) {
+	script := "focus 1 Alacritty vim main.go\nfocus 2 Rofi drun\nretitle 2 run\nretitle 1 vim i3.go\nclose 2"
+	fake, err := NewFake(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignoring := NewIgnoring(fake, ignoreRulesExample)
+
+	var events []Event
+	var titles []string
+	ignoring.DetectWindowTitleChanges(func(event Event) {
+		events = append(events, event)
+		titles = append(titles, ignoring.ActiveWindowTitle())
+	}, func(err error) {
+		t.Fatal(err)
+	})
+
+	expected := []Event{
+		{Type: FocusChanged, WindowID: 1, Title: "vim main.go"},
+		{Type: TitleChanged, WindowID: 1, Title: "vim i3.go"},
+		{Type: WindowClosed, WindowID: 2},
+	}
+	// window 1 keeps showing while the launcher is up, even when retitled
+	expectedTitles := []string{"vim main.go", "vim i3.go", ""}
+	if len(events) != len(expected) {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("Expected %+v, got %+v", expected[i], events[i])
+		}
+		if titles[i] != expectedTitles[i] {
+			t.Fatalf("Expected title %q, got %q", expectedTitles[i], titles[i])
+		}
+	}
+}
+
+func TestIgnoringNothingBefore(t *testing.T) {
+	fake, err := NewFake(strings.NewReader("focus 2 Rofi drun"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignoring := NewIgnoring(fake, ignoreRulesExample)
+	fake.DetectWindowTitleChanges(func(Event) {}, func(error) {})
+
+	if _, err := ignoring.ActiveWindow(); err != ErrNoActiveWindow {
+		t.Fatalf("Expected no active window, got %v", err)
+	}
+}
+
+// Counts the lookups of the active window.
+type countingAPI struct {
+	*Fake
+	lookups int
+}
+
+func (api *countingAPI) ActiveWindow() (Info, error) {
+	api.lookups++
+	return api.Fake.ActiveWindow()
+}
+
+func TestIgnoringLooksUpOnlyOnFocus(t *testing.T) {
+	script := strings.Join([]string{
+		"focus 1 Alacritty vim main.go",
+		"retitle 1 vim i3.go",
+		"workspace 2:web",
+		"urgent 3 on",
+		"focus 2 Rofi drun",
+		"retitle 2 run",
+		"state 2 above",
+		"urgent 2 on",
+		"retitle 1 vim window.go",
+		"state 1 fullscreen",
+	}, "\n")
+	fake, err := NewFake(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &countingAPI{Fake: fake}
+	ignoring := NewIgnoring(api, ignoreRulesExample)
+
+	var events []Event
+	ignoring.DetectWindowTitleChanges(func(event Event) {
+		events = append(events, event)
+	}, func(err error) {
+		t.Fatal(err)
+	})
+
+	if api.lookups != 2 {
+		t.Fatalf("Expected a lookup for each focus change, got %d", api.lookups)
+	}
+	for _, event := range events {
+		if event.WindowID == 2 {
+			t.Fatalf("Expected every event about the launcher dropped, got %+v", event)
+		}
+	}
+	if len(events) != 6 {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Every i3 IPC message starts with this magic string, followed by the payload
+// length and the message type as 32-bit integers in native byte order.
+const ipcMagic = "i3-ipc"
+const ipcHeaderSize = len(ipcMagic) + 4 + 4
+
+// Message types sent to the window manager.
+const (
+	ipcGetWorkspaces uint32 = 1
+	ipcSubscribe     uint32 = 2
+	ipcGetTree       uint32 = 4
+)
+
+// Event types have the highest bit set to tell them apart from replies.
+const (
+	ipcEventMask      uint32 = 1 << 31
+	ipcEventWorkspace uint32 = ipcEventMask | 0
+	ipcEventWindow    uint32 = ipcEventMask | 3
+)
+
+var ErrBadIPCMagic = errors.New("IPC message did not start with the i3-ipc magic string")
+
+// ipcConn is a single connection to an i3 compatible IPC socket.
+type ipcConn struct {
+	conn net.Conn
+}
+
+// ipcNode is a container in the layout tree, trimmed down to the parts needed
+// to describe a window.
+type ipcNode struct {
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	Type             string    `json:"type"`
+	Focused          bool      `json:"focused"`
+	Urgent           bool      `json:"urgent"`
+	FullscreenMode   int       `json:"fullscreen_mode"`
+	Sticky           bool      `json:"sticky"`
+	Marks            []string  `json:"marks"`
+	Floating         string    `json:"floating"`
+	WindowType       string    `json:"window_type"`
+	WindowProperties ipcWindow `json:"window_properties"`
+	Nodes            []ipcNode `json:"nodes"`
+	FloatingNodes    []ipcNode `json:"floating_nodes"`
+
+	// These are only sent by sway.
+	AppID string `json:"app_id"`
+	PID   int    `json:"pid"`
+}
+
+// ipcWindow holds the X11 properties of the window inside a container.
+type ipcWindow struct {
+	Class    string `json:"class"`
+	Instance string `json:"instance"`
+	Title    string `json:"title"`
+	Role     string `json:"window_role"`
+}
+
+// ipcWindowEvent is the payload of a window event.
+type ipcWindowEvent struct {
+	Change    string  `json:"change"`
+	Container ipcNode `json:"container"`
+}
+
+// ipcWorkspace is a workspace as listed by GET_WORKSPACES and sent along with
+// workspace events.
+type ipcWorkspace struct {
+	Name    string `json:"name"`
+	Focused bool   `json:"focused"`
+}
+
+// ipcWorkspaceEvent is the payload of a workspace event.
+type ipcWorkspaceEvent struct {
+	Change  string        `json:"change"`
+	Current *ipcWorkspace `json:"current"`
+}
+
+// ipcSubscribeReply is the reply to a subscribe message.
+type ipcSubscribeReply struct {
+	Success bool `json:"success"`
+}
+
+func dialIPC(socketPath string) (*ipcConn, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &ipcConn{conn: conn}, nil
+}
+
+func (c *ipcConn) Close() error {
+	return c.conn.Close()
+}
+
+// Send a single message of the given type.
+func (c *ipcConn) send(messageType uint32, payload []byte) error {
+	message := make([]byte, ipcHeaderSize, ipcHeaderSize+len(payload))
+	copy(message, ipcMagic)
+	binary.NativeEndian.PutUint32(message[len(ipcMagic):], uint32(len(payload)))
+	binary.NativeEndian.PutUint32(message[len(ipcMagic)+4:], messageType)
+	message = append(message, payload...)
+	_, err := c.conn.Write(message)
+	return err
+}
+
+// Block until the next message arrives and return its type and payload.
+func (c *ipcConn) receive() (uint32, []byte, error) {
+	header := make([]byte, ipcHeaderSize)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(ipcMagic)]) != ipcMagic {
+		return 0, nil, ErrBadIPCMagic
+	}
+	length := binary.NativeEndian.Uint32(header[len(ipcMagic):])
+	messageType := binary.NativeEndian.Uint32(header[len(ipcMagic)+4:])
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return 0, nil, err
+	}
+	return messageType, payload, nil
+}
+
+// Send a message and decode the JSON reply into the given value. Events that
+// arrive before the reply are skipped.
+func (c *ipcConn) request(messageType uint32, payload []byte, reply interface{}) error {
+	if err := c.send(messageType, payload); err != nil {
+		return err
+	}
+	for {
+		replyType, replyPayload, err := c.receive()
+		if err != nil {
+			return err
+		}
+		if replyType&ipcEventMask != 0 {
+			continue
+		}
+		if replyType != messageType {
+			return fmt.Errorf("IPC reply type %d does not match request type %d", replyType, messageType)
+		}
+		return json.Unmarshal(replyPayload, reply)
+	}
+}
+
+// Subscribe this connection to the named events.
+func (c *ipcConn) subscribe(events ...string) error {
+	payload, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	var reply ipcSubscribeReply
+	if err := c.request(ipcSubscribe, payload, &reply); err != nil {
+		return err
+	}
+	if !reply.Success {
+		return fmt.Errorf("IPC subscription to %v was rejected", events)
+	}
+	return nil
+}
+
+// Fetch the whole layout tree.
+func (c *ipcConn) tree() (*ipcNode, error) {
+	var root ipcNode
+	if err := c.request(ipcGetTree, nil, &root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// Fetch the list of workspaces.
+func (c *ipcConn) workspaces() ([]ipcWorkspace, error) {
+	var workspaces []ipcWorkspace
+	if err := c.request(ipcGetWorkspaces, nil, &workspaces); err != nil {
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// Find the focused window in the tree along with the name of the workspace it
+// lives on. Returns nil when no window has focus.
+func focusedNode(node *ipcNode, workspace string) (*ipcNode, string) {
+	if node.Type == "workspace" {
+		workspace = node.Name
+	}
+	if node.Focused && node.Type != "workspace" && node.Type != "output" && node.Type != "root" {
+		return node, workspace
+	}
+	for _, children := range [][]ipcNode{node.Nodes, node.FloatingNodes} {
+		for i := range children {
+			if found, foundWorkspace := focusedNode(&children[i], workspace); found != nil {
+				return found, foundWorkspace
+			}
+		}
+	}
+	return nil, ""
+}
+
+// Collect every window in the tree demanding attention along with the name of
+// the workspace it lives on.
+func urgentNodes(node *ipcNode, workspace string, urgent []Info) []Info {
+	if node.Type == "workspace" {
+		workspace = node.Name
+	}
+	isWindow := len(node.Nodes) == 0 && len(node.FloatingNodes) == 0 &&
+		node.Type != "workspace" && node.Type != "output" && node.Type != "root"
+	if isWindow && node.Urgent {
+		urgent = append(urgent, node.info(workspace))
+	}
+	for _, children := range [][]ipcNode{node.Nodes, node.FloatingNodes} {
+		for i := range children {
+			urgent = urgentNodes(&children[i], workspace, urgent)
+		}
+	}
+	return urgent
+}
+
+// Convert a container into an Info.
+func (node *ipcNode) info(workspace string) Info {
+	class := node.WindowProperties.Class
+	if node.AppID != "" {
+		// native Wayland windows under sway have an app_id instead of a class
+		class = node.AppID
+	}
+	var types []string
+	if node.WindowType != "" {
+		types = []string{node.WindowType}
+	}
+	var states []string
+	if node.FullscreenMode != 0 {
+		states = append(states, "fullscreen")
+	}
+	if node.Sticky {
+		states = append(states, "sticky")
+	}
+	return Info{
+		ID:        uint64(node.ID),
+		Title:     node.Name,
+		Class:     class,
+		Instance:  node.WindowProperties.Instance,
+		Types:     types,
+		Role:      node.WindowProperties.Role,
+		Marks:     node.Marks,
+		Floating:  node.Floating == "auto_on" || node.Floating == "user_on" || node.Type == "floating_con",
+		Workspace: workspace,
+		PID:       node.PID,
+		Urgent:    node.Urgent,
+		States:    states,
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// fakeIPCEvent is an event pushed by fakeIPCServer after a subscription.
+type fakeIPCEvent struct {
+	eventType uint32
+	payload   string
+}
+
+// fakeIPCServer speaks just enough of the i3 IPC protocol to answer tree
+// requests and push a fixed list of events to subscribers.
+type fakeIPCServer struct {
+	socketPath string
+	listener   net.Listener
+	tree       string
+	events     []fakeIPCEvent
+	workspaces string
+}
+
+func newFakeIPCServer(t *testing.T, tree string, events ...fakeIPCEvent) *fakeIPCServer {
+	socketPath := filepath.Join(t.TempDir(), "ipc.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeIPCServer{socketPath, listener, tree, events, "[]"}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (server *fakeIPCServer) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.handle(&ipcConn{conn: conn})
+	}
+}
+
+func (server *fakeIPCServer) handle(conn *ipcConn) {
+	defer conn.Close()
+	for {
+		messageType, _, err := conn.receive()
+		if err != nil {
+			return
+		}
+		switch messageType {
+		case ipcGetTree:
+			conn.send(ipcGetTree, []byte(server.tree))
+		case ipcGetWorkspaces:
+			conn.send(ipcGetWorkspaces, []byte(server.workspaces))
+		case ipcSubscribe:
+			conn.send(ipcSubscribe, []byte(`{"success":true}`))
+			for _, event := range server.events {
+				conn.send(event.eventType, []byte(event.payload))
+			}
+			// hang up once everything is sent to end the event loop
+			return
+		}
+	}
+}
+
+func TestIPCFraming(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go (&ipcConn{conn: client}).send(ipcGetTree, []byte(`{"id":1}`))
+	messageType, payload, err := (&ipcConn{conn: server}).receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageType != ipcGetTree {
+		t.Fatalf("Unexpected message type %d", messageType)
+	}
+	if string(payload) != `{"id":1}` {
+		t.Fatalf("Unexpected payload %s", payload)
+	}
+}
+
+func TestIPCBadMagic(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go client.Write([]byte("potato-and-more"))
+	_, _, err := (&ipcConn{conn: server}).receive()
+	if err != ErrBadIPCMagic {
+		t.Fatalf("Expected bad magic error, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// AutoBackend is the name that asks Detect to pick a backend.
+const AutoBackend = "auto"
+
+var ErrNoBackend = errors.New("no window backend is usable in this environment")
+
+// Options carries what backends need to probe the environment and connect.
+type Options struct {
+	// Getenv looks up environment variables, os.Getenv when nil.
+	Getenv func(string) string
+
+	// FakeScript is the path of the script replayed by the fake backend.
+	FakeScript string
+
+	// Output restricts the x11 backend to windows on this RandR output.
+	Output string
+
+	// Display is the X11 display the x11 backend connects to, $DISPLAY when
+	// empty.
+	Display string
+}
+
+func (options Options) getenv(key string) string {
+	if options.Getenv == nil {
+		return os.Getenv(key)
+	}
+	return options.Getenv(key)
+}
+
+// Find an executable in the PATH looked up through Getenv.
+func (options Options) lookPath(file string) (string, error) {
+	if options.Getenv == nil {
+		return exec.LookPath(file)
+	}
+	for _, dir := range filepath.SplitList(options.Getenv("PATH")) {
+		path := filepath.Join(dir, file)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return "", exec.ErrNotFound
+}
+
+// Backend is an implementation of API that can be picked by name.
+type Backend struct {
+	// Name is what the backend is selected by, like "x11".
+	Name string
+
+	// Priority orders backends during detection, lowest first.
+	Priority int
+
+	// Probe checks whether the backend looks usable, returning a short reason
+	// why it does or an error explaining why it does not.
+	Probe func(options Options) (string, error)
+
+	// Open connects the backend.
+	Open func(options Options) (API, error)
+}
+
+// Rejection records why detection skipped a backend.
+type Rejection struct {
+	Name   string
+	Reason error
+}
+
+// Detection reports which backend Detect picked and why.
+type Detection struct {
+	// Name is the picked backend.
+	Name string
+
+	// Reason is what its probe found.
+	Reason string
+
+	// Rejected lists every backend tried before it, in order.
+	Rejected []Rejection
+}
+
+// Registry holds the known backends.
+type Registry struct {
+	backends []Backend
+}
+
+// DefaultRegistry is where every backend in this package registers itself.
+var DefaultRegistry = &Registry{}
+
+// Register adds a backend to the DefaultRegistry.
+func Register(backend Backend) {
+	DefaultRegistry.Register(backend)
+}
+
+// Register adds a backend, replacing any other with the same name.
+func (registry *Registry) Register(backend Backend) {
+	for i := range registry.backends {
+		if registry.backends[i].Name == backend.Name {
+			registry.backends[i] = backend
+			return
+		}
+	}
+	registry.backends = append(registry.backends, backend)
+	sort.SliceStable(registry.backends, func(i, j int) bool {
+		return registry.backends[i].Priority < registry.backends[j].Priority
+	})
+}
+
+// Names returns the names of all registered backends in detection order.
+func (registry *Registry) Names() []string {
+	names := make([]string, len(registry.backends))
+	for i, backend := range registry.backends {
+		names[i] = backend.Name
+	}
+	return names
+}
+
+// Lookup finds a registered backend by name.
+func (registry *Registry) Lookup(name string) (Backend, bool) {
+	for _, backend := range registry.backends {
+		if backend.Name == name {
+			return backend, true
+		}
+	}
+	return Backend{}, false
+}
+
+// Open connects the named backend, or detects one for AutoBackend.
+func (registry *Registry) Open(name string, options Options) (API, Detection, error) {
+	if name == AutoBackend {
+		return registry.Detect(options)
+	}
+	backend, found := registry.Lookup(name)
+	if !found {
+		return nil, Detection{}, fmt.Errorf("unknown window backend: %s", name)
+	}
+	api, err := backend.Open(options)
+	if err != nil {
+		return nil, Detection{}, fmt.Errorf("window backend %s: %w", name, err)
+	}
+	return api, Detection{Name: name, Reason: "selected explicitly"}, nil
+}
+
+// Detect tries every backend in priority order, returning the first one that
+// both probes and opens successfully along with why the others were rejected.
+func (registry *Registry) Detect(options Options) (API, Detection, error) {
+	var detection Detection
+	for _, backend := range registry.backends {
+		reason, err := backend.Probe(options)
+		if err != nil {
+			detection.Rejected = append(detection.Rejected, Rejection{backend.Name, err})
+			continue
+		}
+		api, err := backend.Open(options)
+		if err != nil {
+			detection.Rejected = append(detection.Rejected, Rejection{backend.Name, err})
+			continue
+		}
+		detection.Name, detection.Reason = backend.Name, reason
+		return api, detection, nil
+	}
+	return nil, detection, ErrNoBackend
+}
+
+// Probe for a backend that is usable whenever the given environment variable
+// is set.
+func probeEnv(key string) func(options Options) (string, error) {
+	return func(options Options) (string, error) {
+		value := options.getenv(key)
+		if value == "" {
+			return "", fmt.Errorf("%s is not set", key)
+		}
+		return fmt.Sprintf("%s is set to %s", key, value), nil
+	}
+}
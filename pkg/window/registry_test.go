@@ -0,0 +1,125 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"errors"
+	"testing"
+)
+
+type nullAPI struct{ name string }
+
+func (api nullAPI) ActiveWindowTitle() string {
+	return api.name
+}
+
+func (api nullAPI) ActiveWindow() (Info, error) {
+	return Info{Title: api.name}, nil
+}
+
+func (api nullAPI) DetectWindowTitleChanges(onChange func(Event), onError func(error)) error {
+	return nil
+}
+
+func testBackend(name string, priority int, probeKey string, openErr error) Backend {
+	return Backend{
+		Name:     name,
+		Priority: priority,
+		Probe:    probeEnv(probeKey),
+		Open: func(options Options) (API, error) {
+			if openErr != nil {
+				return nil, openErr
+			}
+			return nullAPI{name}, nil
+		},
+	}
+}
+
+func testEnv(env map[string]string) Options {
+	return Options{Getenv: func(key string) string { return env[key] }}
+}
+
+func TestRegistryDetectOrder(t *testing.T) {
+	registry := &Registry{}
+	registry.Register(testBackend("last", 30, "LAST", nil))
+	registry.Register(testBackend("first", 10, "FIRST", nil))
+	registry.Register(testBackend("broken", 20, "BROKEN", errors.New("kaboom")))
+
+	names := registry.Names()
+	if len(names) != 3 || names[0] != "first" || names[1] != "broken" || names[2] != "last" {
+		t.Fatalf("Unexpected order %v", names)
+	}
+
+	api, detection, err := registry.Detect(testEnv(map[string]string{"BROKEN": "1", "LAST": "1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.ActiveWindowTitle() != "last" || detection.Name != "last" {
+		t.Fatalf("Unexpected detection %+v", detection)
+	}
+	if detection.Reason != "LAST is set to 1" {
+		t.Fatalf("Unexpected reason %s", detection.Reason)
+	}
+	if len(detection.Rejected) != 2 {
+		t.Fatalf("Unexpected rejections %+v", detection.Rejected)
+	}
+	if detection.Rejected[0].Name != "first" || detection.Rejected[0].Reason.Error() != "FIRST is not set" {
+		t.Fatalf("Unexpected rejection %+v", detection.Rejected[0])
+	}
+	if detection.Rejected[1].Name != "broken" || detection.Rejected[1].Reason.Error() != "kaboom" {
+		t.Fatalf("Unexpected rejection %+v", detection.Rejected[1])
+	}
+}
+
+func TestRegistryDetectNothingUsable(t *testing.T) {
+	registry := &Registry{}
+	registry.Register(testBackend("first", 10, "FIRST", nil))
+
+	_, detection, err := registry.Detect(testEnv(nil))
+	if err != ErrNoBackend {
+		t.Fatalf("Expected no backend error, got %v", err)
+	}
+	if len(detection.Rejected) != 1 {
+		t.Fatalf("Unexpected rejections %+v", detection.Rejected)
+	}
+}
+
+func TestRegistryOpenByName(t *testing.T) {
+	registry := &Registry{}
+	registry.Register(testBackend("first", 10, "FIRST", nil))
+	registry.Register(testBackend("first", 10, "FIRST", errors.New("replaced")))
+
+	_, _, err := registry.Open("first", testEnv(nil))
+	if err == nil || err.Error() != "window backend first: replaced" {
+		t.Fatalf("Expected the replaced backend to be opened, got %v", err)
+	}
+	_, _, err = registry.Open("potato", testEnv(nil))
+	if err == nil {
+		t.Fatal("Expected unknown backend error")
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	names := DefaultRegistry.Names()
+	expected := []string{"sway", "hyprland", "x11", "i3ipc", "fake"}
+	if len(names) != len(expected) {
+		t.Fatalf("Unexpected backends %v", names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Unexpected backends %v", names)
+		}
+	}
+}
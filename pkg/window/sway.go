@@ -0,0 +1,81 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"errors"
+)
+
+var ErrNoSwaySocket = errors.New("sway IPC socket could not be found, is SWAYSOCK set or sway in the PATH?")
+
+func init() {
+	Register(Backend{
+		Name:     "sway",
+		Priority: 10,
+		Probe:    probeSway,
+		Open: func(options Options) (API, error) {
+			return newSway(options)
+		},
+	})
+}
+
+// Sway is usable with SWAYSOCK set, or under Wayland with sway in the PATH to
+// ask for its socket, like when SWAYSOCK isn't exported to a systemd user unit.
+func probeSway(options Options) (string, error) {
+	if socketPath := options.getenv("SWAYSOCK"); socketPath != "" {
+		return "SWAYSOCK is set to " + socketPath, nil
+	}
+	if options.getenv("WAYLAND_DISPLAY") == "" {
+		return "", errors.New("neither SWAYSOCK nor WAYLAND_DISPLAY is set")
+	}
+	if _, err := options.lookPath("sway"); err != nil {
+		return "", ErrNoSwaySocket
+	}
+	return "WAYLAND_DISPLAY is set and sway is in the PATH", nil
+}
+
+// Sway watches windows through the IPC socket of a running sway instance. Sway
+// speaks the same protocol as i3, adding the app_id and pid of each window to
+// the tree, so everything but finding the socket is shared with I3IPC.
+type Sway struct {
+	I3IPC
+}
+
+// NewSway finds the socket of the running sway instance, first from SWAYSOCK
+// and then by asking sway itself, and makes sure it accepts connections.
+func NewSway() (*Sway, error) {
+	return newSway(Options{})
+}
+
+func newSway(options Options) (*Sway, error) {
+	socketPath, err := findSocket(options, "SWAYSOCK", "sway")
+	if err != nil {
+		return nil, ErrNoSwaySocket
+	}
+	return NewSwayForSocket(socketPath)
+}
+
+// NewSwayForSocket creates a Sway for the given socket path, making sure it
+// accepts connections.
+func NewSwayForSocket(socketPath string) (*Sway, error) {
+	if socketPath == "" {
+		return nil, ErrNoSwaySocket
+	}
+	i3, err := NewI3IPCForSocket(socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Sway{*i3}, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const swayTreeExample = `{"id":1,"type":"root","nodes":[
+	{"id":2,"type":"output","name":"eDP-1","nodes":[
+		{"id":3,"type":"workspace","name":"3","nodes":[
+			{"id":4,"type":"con","name":"Slack","app_id":null,"pid":1234,
+				"window_properties":{"class":"Slack","instance":"slack"}},
+			{"id":5,"type":"con","name":"~/src","focused":true,"app_id":"foot","pid":4321}
+		]}
+	]}
+]}`
+
+func TestSwayActiveWindow(t *testing.T) {
+	server := newFakeIPCServer(t, swayTreeExample)
+	api, err := NewSwayForSocket(server.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := api.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != 5 || info.Title != "~/src" || info.Class != "foot" || info.PID != 4321 {
+		t.Fatalf("Unexpected window %+v", info)
+	}
+	if info.Floating {
+		t.Fatal("Expected tiled window")
+	}
+	if info.Workspace != "3" {
+		t.Fatalf("Unexpected workspace %s", info.Workspace)
+	}
+}
+
+func TestSwayXWaylandClass(t *testing.T) {
+	node := ipcNode{ID: 4, Name: "Slack", PID: 1234, WindowProperties: ipcWindow{Class: "Slack"}}
+	if info := node.info("3"); info.Class != "Slack" || info.PID != 1234 {
+		t.Fatalf("Unexpected window %+v", info)
+	}
+}
+
+func TestSwayMissingSocket(t *testing.T) {
+	_, err := NewSwayForSocket("")
+	if err != ErrNoSwaySocket {
+		t.Fatalf("Expected missing socket error, got %v", err)
+	}
+}
+
+func TestSwayProbe(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sway"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		env    map[string]string
+		reason string
+	}{
+		{map[string]string{"SWAYSOCK": "/run/user/1000/sway.sock"}, "SWAYSOCK is set to /run/user/1000/sway.sock"},
+		{map[string]string{"WAYLAND_DISPLAY": "wayland-1", "PATH": dir}, "WAYLAND_DISPLAY is set and sway is in the PATH"},
+		{map[string]string{"WAYLAND_DISPLAY": "wayland-1", "PATH": t.TempDir()}, ""},
+		{map[string]string{"PATH": dir}, ""},
+	}
+	for _, test := range tests {
+		reason, err := probeSway(testEnv(test.env))
+		if test.reason == "" {
+			if err == nil {
+				t.Errorf("Expected %v to be rejected, got %s", test.env, reason)
+			}
+		} else if err != nil || reason != test.reason {
+			t.Errorf("Expected %q for %v, got %q and %v", test.reason, test.env, reason, err)
+		}
+	}
+}
+
+func TestSwayOpenFromOptions(t *testing.T) {
+	server := newFakeIPCServer(t, swayTreeExample)
+	t.Setenv("SWAYSOCK", "")
+	backend, _ := DefaultRegistry.Lookup("sway")
+	api, err := backend.Open(testEnv(map[string]string{"SWAYSOCK": server.socketPath}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.(*Sway).SocketPath != server.socketPath {
+		t.Fatalf("Expected the socket from the options, got %s", api.(*Sway).SocketPath)
+	}
+}
@@ -14,15 +14,317 @@
 
 package window
 
+import (
+	"errors"
+)
+
+// ErrNoActiveWindow is returned when no window currently has focus, such as on
+// an empty workspace.
+var ErrNoActiveWindow = errors.New("no window is currently active")
+
+// ErrNoWorkspaces is returned when a backend doesn't know about workspaces.
+var ErrNoWorkspaces = errors.New("window backend does not report workspaces")
+
+// ErrNoUrgency is returned when a backend doesn't know which windows demand
+// attention.
+var ErrNoUrgency = errors.New("window backend does not report urgent windows")
+
 // API defines the functions necessary to monitor window activity.
 type API interface {
 
 	// ActiveWindowTitle returns the currently active window's title.
 	ActiveWindowTitle() string
 
+	// ActiveWindow returns everything the backend knows about the currently
+	// active window. Fields a backend can't provide are left empty.
+	ActiveWindow() (Info, error)
+
 	// DetectWindowTitleChanges blocks and starts detecting changes in window
-	// titles. When a change is detected, the onChange function is called and
-	// when a non-fatal error occurs the onError function is called for that
-	// error.
-	DetectWindowTitleChanges(onChange func(), onError func(error)) error
+	// titles. When a change is detected, the onChange function is called with
+	// an Event describing it and when a non-fatal error occurs the onError
+	// function is called for that error.
+	DetectWindowTitleChanges(onChange func(Event), onError func(error)) error
+}
+
+// WorkspaceAPI is implemented by backends that can tell which workspace, or
+// desktop, is current.
+type WorkspaceAPI interface {
+
+	// CurrentWorkspace returns the name of the current workspace.
+	CurrentWorkspace() (string, error)
+}
+
+// CurrentWorkspace returns the name of the current workspace of the given API
+// or ErrNoWorkspaces if it doesn't know about them.
+func CurrentWorkspace(api API) (string, error) {
+	if workspaceAPI, ok := api.(WorkspaceAPI); ok {
+		return workspaceAPI.CurrentWorkspace()
+	}
+	return "", ErrNoWorkspaces
+}
+
+// UrgencyAPI is implemented by backends that can tell which windows are
+// demanding attention.
+type UrgencyAPI interface {
+
+	// UrgentWindows returns all windows currently demanding attention.
+	UrgentWindows() ([]Info, error)
+}
+
+// UrgentWindows returns the windows of the given API demanding attention or
+// ErrNoUrgency if it doesn't know about urgency.
+func UrgentWindows(api API) ([]Info, error) {
+	if urgencyAPI, ok := api.(UrgencyAPI); ok {
+		return urgencyAPI.UrgentWindows()
+	}
+	return nil, ErrNoUrgency
+}
+
+// Info describes a single window as reported by a backend.
+type Info struct {
+	// ID identifies the window within its backend, like an X11 window id or
+	// an i3 container id.
+	ID uint64
+
+	// Title is the current window title.
+	Title string
+
+	// Class is the window class, the second part of WM_CLASS, or the app_id of
+	// a native Wayland window.
+	Class string
+
+	// Instance is the window instance, the first part of WM_CLASS.
+	Instance string
+
+	// Types are the window types from _NET_WM_WINDOW_TYPE without their
+	// prefix, like "normal" or "dialog".
+	Types []string
+
+	// Role is the WM_WINDOW_ROLE of the window, like "browser".
+	Role string
+
+	// Marks are the i3 marks set on the window's container.
+	Marks []string
+
+	// Floating is true when the window is not tiled.
+	Floating bool
+
+	// Workspace is the name of the workspace the window is on.
+	Workspace string
+
+	// PID is the process id owning the window, 0 when unknown.
+	PID int
+
+	// Urgent is true when the window demands attention.
+	Urgent bool
+
+	// States are the window states from _NET_WM_STATE without their prefix,
+	// like "fullscreen" or "sticky". Other backends map what they know onto
+	// the same names.
+	States []string
+}
+
+// EventType identifies the kind of change an Event describes.
+type EventType int
+
+const (
+	// FocusChanged means a different window became the active window.
+	FocusChanged EventType = iota
+
+	// TitleChanged means the title of a window was rewritten.
+	TitleChanged
+
+	// WindowClosed means a window went away.
+	WindowClosed
+
+	// WorkspaceChanged means a different workspace became current or the
+	// current one was renamed.
+	WorkspaceChanged
+
+	// UrgencyChanged means a window started or stopped demanding attention.
+	UrgencyChanged
+
+	// StateChanged means a window was made fullscreen, sticky or the like, or
+	// stopped being so.
+	StateChanged
+)
+
+func (eventType EventType) String() string {
+	switch eventType {
+	case FocusChanged:
+		return "focus"
+	case TitleChanged:
+		return "title"
+	case WindowClosed:
+		return "close"
+	case WorkspaceChanged:
+		return "workspace"
+	case UrgencyChanged:
+		return "urgency"
+	case StateChanged:
+		return "state"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is emitted by an API whenever something about a window changes. A
+// WindowID of 0 means the backend could not identify the window.
+type Event struct {
+	// Type is the kind of change that occurred.
+	Type EventType
+
+	// WindowID identifies the window the change applies to. For FocusChanged
+	// this is the newly active window.
+	WindowID uint64
+
+	// Title is the new title of the window, empty for WindowClosed. For
+	// WorkspaceChanged, it is the name of the current workspace instead.
+	Title string
+
+	// Urgent tells whether the window now demands attention, only set for
+	// UrgencyChanged.
+	Urgent bool
+}
+
+// ChangeFilter remembers the last active window and title that were let
+// through, along with the windows demanding attention, and reports whether a
+// new Event changes anything visible. It is not safe for concurrent use and
+// is meant to sit right behind a single DetectWindowTitleChanges loop.
+type ChangeFilter struct {
+	// ActiveWindow looks up the active window when the filter is seeded and
+	// after the active window closes, so retitles of background windows are
+	// dropped before the next focus change. Usually API.ActiveWindow.
+	ActiveWindow func() (Info, error)
+
+	known     bool
+	activeID  uint64
+	title     string
+	workspace *string
+	urgent    map[uint64]string
+}
+
+// Seed starts the filter from the window ActiveWindow reports, call it before
+// the DetectWindowTitleChanges loop starts.
+func (filter *ChangeFilter) Seed() {
+	filter.seed(0)
+}
+
+// Look up the active window, unless it's the given closed one that the window
+// manager hasn't moved away from yet.
+func (filter *ChangeFilter) seed(closedID uint64) {
+	filter.known = false
+	if filter.ActiveWindow == nil {
+		return
+	}
+	info, err := filter.ActiveWindow()
+	if err != nil || info.ID == 0 || info.ID == closedID {
+		return
+	}
+	filter.accept(Event{Type: FocusChanged, WindowID: info.ID, Title: info.Title})
+}
+
+// Changed reports whether the given Event would alter the displayed title,
+// updating the remembered state when it does.
+func (filter *ChangeFilter) Changed(event Event) bool {
+	if event.Type == WorkspaceChanged {
+		if filter.workspace != nil && *filter.workspace == event.Title {
+			return false
+		}
+		filter.workspace = &event.Title
+		return true
+	}
+	if event.Type == UrgencyChanged {
+		return filter.urgencyChanged(event)
+	}
+	urgentChanged := filter.urgentWindowChanged(event)
+	return filter.activeChanged(event) || urgentChanged
+}
+
+// Report whether the given Event changes the active window or its title,
+// updating the remembered state when it does.
+func (filter *ChangeFilter) activeChanged(event Event) bool {
+	if !filter.known {
+		// only a focus change tells us which window is active
+		switch event.Type {
+		case FocusChanged:
+			filter.accept(event)
+		case TitleChanged:
+			// no window was active when the filter was seeded, so one that
+			// retitles itself is in the background
+			return event.WindowID == 0
+		}
+		return true
+	}
+
+	switch event.Type {
+	case FocusChanged:
+		if event.WindowID == filter.activeID && event.Title == filter.title {
+			return false
+		}
+	case TitleChanged:
+		// retitling some other window in the background is invisible
+		if event.WindowID != 0 && event.WindowID != filter.activeID {
+			return false
+		}
+		if event.Title == filter.title {
+			return false
+		}
+		event.WindowID = filter.activeID
+	case StateChanged:
+		// only the states of the active window are shown
+		return event.WindowID == 0 || event.WindowID == filter.activeID
+	case WindowClosed:
+		if event.WindowID != 0 && event.WindowID != filter.activeID {
+			return false
+		}
+		// start over from whatever is focused instead
+		filter.seed(event.WindowID)
+		return true
+	}
+
+	filter.accept(event)
+	return true
+}
+
+// Keep the titles of windows demanding attention current, reporting whether
+// the given Event changed one, since they're shown even in the background.
+func (filter *ChangeFilter) urgentWindowChanged(event Event) bool {
+	title, urgent := filter.urgent[event.WindowID]
+	if !urgent || event.WindowID == 0 {
+		return false
+	}
+	switch event.Type {
+	case TitleChanged:
+		filter.urgent[event.WindowID] = event.Title
+		return title != event.Title
+	case WindowClosed:
+		delete(filter.urgent, event.WindowID)
+		return true
+	}
+	return false
+}
+
+// Remember whether the window of the given UrgencyChanged Event demands
+// attention, reporting whether that's news.
+func (filter *ChangeFilter) urgencyChanged(event Event) bool {
+	title, urgent := filter.urgent[event.WindowID]
+	if !event.Urgent {
+		delete(filter.urgent, event.WindowID)
+		return urgent
+	}
+	if urgent && title == event.Title {
+		return false
+	}
+	if filter.urgent == nil {
+		filter.urgent = map[uint64]string{}
+	}
+	filter.urgent[event.WindowID] = event.Title
+	return true
+}
+
+func (filter *ChangeFilter) accept(event Event) {
+	filter.known = true
+	filter.activeID = event.WindowID
+	filter.title = event.Title
 }
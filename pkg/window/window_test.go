@@ -0,0 +1,171 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"testing"
+)
+
+func TestChangeFilterFirstEventPasses(t *testing.T) {
+	var filter ChangeFilter
+	if filter.Changed(Event{Type: TitleChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected a retitle with no active window known to be suppressed")
+	}
+	if !filter.Changed(Event{Type: TitleChanged, Title: "foo"}) {
+		t.Fatal("Expected a retitle of an unknown window to pass")
+	}
+	if !filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected first focus event to pass")
+	}
+}
+
+func TestChangeFilterSeeded(t *testing.T) {
+	active := Info{ID: 1, Title: "foo"}
+	filter := ChangeFilter{ActiveWindow: func() (Info, error) {
+		return active, nil
+	}}
+	filter.Seed()
+	if filter.Changed(Event{Type: TitleChanged, WindowID: 2, Title: "bar"}) {
+		t.Fatal("Expected a background retitle before any focus change to be suppressed")
+	}
+	if filter.Changed(Event{Type: TitleChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected the seeded title to be suppressed")
+	}
+	if !filter.Changed(Event{Type: TitleChanged, WindowID: 1, Title: "baz"}) {
+		t.Fatal("Expected a retitle of the seeded window to pass")
+	}
+
+	// the window manager already moved on to window 3 when 1 closes
+	active = Info{ID: 3, Title: "qux"}
+	if !filter.Changed(Event{Type: WindowClosed, WindowID: 1}) {
+		t.Fatal("Expected active window close to pass")
+	}
+	if filter.Changed(Event{Type: TitleChanged, WindowID: 2, Title: "bar"}) {
+		t.Fatal("Expected a background retitle after the close to be suppressed")
+	}
+	if !filter.Changed(Event{Type: TitleChanged, WindowID: 3, Title: "quux"}) {
+		t.Fatal("Expected a retitle of the newly active window to pass")
+	}
+
+	// or still reports the closed window as active
+	active = Info{ID: 3, Title: "quux"}
+	if !filter.Changed(Event{Type: WindowClosed, WindowID: 3}) || filter.known {
+		t.Fatal("Expected the closed window not to be seeded")
+	}
+}
+
+func TestChangeFilterSameTitleSuppressed(t *testing.T) {
+	var filter ChangeFilter
+	filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"})
+	if filter.Changed(Event{Type: TitleChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected rewrite of the same title to be suppressed")
+	}
+	if filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected refocus of the same window to be suppressed")
+	}
+	if !filter.Changed(Event{Type: TitleChanged, WindowID: 1, Title: "bar"}) {
+		t.Fatal("Expected new title to pass")
+	}
+	if filter.Changed(Event{Type: TitleChanged, WindowID: 1, Title: "bar"}) {
+		t.Fatal("Expected repeated new title to be suppressed")
+	}
+}
+
+func TestChangeFilterBackgroundWindowSuppressed(t *testing.T) {
+	var filter ChangeFilter
+	filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"})
+	if filter.Changed(Event{Type: TitleChanged, WindowID: 2, Title: "bar"}) {
+		t.Fatal("Expected background title change to be suppressed")
+	}
+	if filter.Changed(Event{Type: WindowClosed, WindowID: 2}) {
+		t.Fatal("Expected background window close to be suppressed")
+	}
+	if !filter.Changed(Event{Type: FocusChanged, WindowID: 2, Title: "bar"}) {
+		t.Fatal("Expected focus change to pass")
+	}
+}
+
+func TestChangeFilterActiveWindowClosed(t *testing.T) {
+	var filter ChangeFilter
+	filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"})
+	if !filter.Changed(Event{Type: WindowClosed, WindowID: 1}) {
+		t.Fatal("Expected active window close to pass")
+	}
+	if !filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected focus after close to pass")
+	}
+}
+
+func TestChangeFilterWorkspace(t *testing.T) {
+	var filter ChangeFilter
+	filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"})
+	if !filter.Changed(Event{Type: WorkspaceChanged, Title: "1"}) {
+		t.Fatal("Expected first workspace to pass")
+	}
+	if filter.Changed(Event{Type: WorkspaceChanged, Title: "1"}) {
+		t.Fatal("Expected same workspace to be suppressed")
+	}
+	if !filter.Changed(Event{Type: WorkspaceChanged, Title: "2:web"}) {
+		t.Fatal("Expected new workspace to pass")
+	}
+	if filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected workspace changes to leave the window alone")
+	}
+}
+
+func TestChangeFilterUrgency(t *testing.T) {
+	var filter ChangeFilter
+	filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"})
+	if !filter.Changed(Event{Type: UrgencyChanged, WindowID: 2, Title: "chat", Urgent: true}) {
+		t.Fatal("Expected a window demanding attention to pass")
+	}
+	if filter.Changed(Event{Type: UrgencyChanged, WindowID: 2, Title: "chat", Urgent: true}) {
+		t.Fatal("Expected the same urgency to be suppressed")
+	}
+	if !filter.Changed(Event{Type: TitleChanged, WindowID: 2, Title: "chat (1)"}) {
+		t.Fatal("Expected retitling an urgent background window to pass")
+	}
+	if !filter.Changed(Event{Type: UrgencyChanged, WindowID: 2, Title: "chat (1)"}) {
+		t.Fatal("Expected calming down to pass")
+	}
+	if filter.Changed(Event{Type: UrgencyChanged, WindowID: 2, Title: "chat (1)"}) {
+		t.Fatal("Expected calming down twice to be suppressed")
+	}
+	if filter.Changed(Event{Type: TitleChanged, WindowID: 2, Title: "chat"}) {
+		t.Fatal("Expected retitling a calm background window to be suppressed")
+	}
+
+	filter.Changed(Event{Type: UrgencyChanged, WindowID: 3, Title: "mail", Urgent: true})
+	if !filter.Changed(Event{Type: WindowClosed, WindowID: 3}) {
+		t.Fatal("Expected closing an urgent background window to pass")
+	}
+	if filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected urgency to leave the active window alone")
+	}
+}
+
+func TestChangeFilterState(t *testing.T) {
+	var filter ChangeFilter
+	filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"})
+	if !filter.Changed(Event{Type: StateChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected a state change of the active window to pass")
+	}
+	if filter.Changed(Event{Type: StateChanged, WindowID: 2, Title: "bar"}) {
+		t.Fatal("Expected a state change of a background window to be suppressed")
+	}
+	if filter.Changed(Event{Type: FocusChanged, WindowID: 1, Title: "foo"}) {
+		t.Fatal("Expected state changes to leave the window alone")
+	}
+}
@@ -16,6 +16,11 @@ package window
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/BurntSushi/xgb"
 	"github.com/BurntSushi/xgb/xproto"
@@ -23,11 +28,38 @@ import (
 
 var ErrInvalidReply = errors.New("X11 reply value was not exactly 4 bytes")
 
+func init() {
+	Register(Backend{
+		Name:     "x11",
+		Priority: 30,
+		Probe:    probeEnv("DISPLAY"),
+		Open: func(options Options) (API, error) {
+			display := options.Display
+			if display == "" {
+				display = options.getenv("DISPLAY")
+			}
+			x11, err := NewX11ForDisplay(display)
+			if err != nil {
+				return nil, err
+			}
+			if options.Output != "" {
+				if err := x11.watchOutput(options.Output); err != nil {
+					return nil, err
+				}
+			}
+			return x11, nil
+		},
+	})
+}
+
 // X11 creates and manages the currently active X11 connection.
 type X11 struct {
 	// This is the underlying X11 connection.
 	XConnection *xgb.Conn
 
+	// This is the name of the display the connection is for, like ":0".
+	Display string
+
 	// This is the root window.
 	RootWindow xproto.Window
 
@@ -46,12 +78,144 @@ type X11 struct {
 	// This is another common window title atom. Any changes that occur for it
 	// may indicate the title has been updated.
 	WindowName3Atom xproto.Atom
+
+	// The value of this atom is the list of types of a window, like dialog.
+	WindowTypeAtom xproto.Atom
+
+	// The value of this atom is the role of a window, like browser.
+	WindowRoleAtom xproto.Atom
+
+	// The value of this atom is the process id owning a window.
+	WindowPIDAtom xproto.Atom
+
+	// The value of this atom on the root window is the list of all managed
+	// windows.
+	ClientListAtom xproto.Atom
+
+	// The value of this atom is the list of states of a window, like
+	// fullscreen.
+	WindowStateAtom xproto.Atom
+
+	// This is the window state of a window demanding attention.
+	DemandsAttentionAtom xproto.Atom
+
+	// The value of this atom on the root window is the index of the current
+	// desktop.
+	CurrentDesktopAtom xproto.Atom
+
+	// The value of this atom on the root window is the list of desktop names.
+	DesktopNamesAtom xproto.Atom
+
+	// When set, only windows on this RandR output are reported.
+	Output string
+
+	// These are the recently focused windows, only tracked with an Output.
+	focusHistory *focusHistory
+
+	// These are the names of the window state atoms seen so far.
+	stateNames *atomNames
+
+	// These are the windows demanding attention, kept while the event loop
+	// runs.
+	urgent *urgentCache
+}
+
+// urgentCache keeps the windows demanding attention between the events that
+// can change them, so they aren't looked up on every status line. It is only
+// used while the event loop runs, which is what invalidates it, and is shared
+// between copies of an X11 like focusHistory.
+type urgentCache struct {
+	mutex      sync.Mutex
+	watching   bool
+	fresh      bool
+	generation int
+	windows    []Info
+}
+
+// Get the cached windows, computing them with the given function when the
+// cache isn't fresh. What's computed is only kept when nothing invalidated the
+// cache in the meantime.
+func (cache *urgentCache) get(compute func() ([]Info, error)) ([]Info, error) {
+	cache.mutex.Lock()
+	if cache.watching && cache.fresh {
+		windows := append([]Info(nil), cache.windows...)
+		cache.mutex.Unlock()
+		return windows, nil
+	}
+	generation := cache.generation
+	cache.mutex.Unlock()
+
+	windows, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.watching && cache.generation == generation {
+		cache.windows, cache.fresh = append([]Info(nil), windows...), true
+	}
+	return windows, nil
+}
+
+// Start keeping the computed windows, until the next invalidate.
+func (cache *urgentCache) watch() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.watching = true
+}
+
+// Forget the cached windows after something that may change them.
+func (cache *urgentCache) invalidate() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.fresh = false
+	cache.generation++
+}
+
+// Report whether the given window is one of the cached ones, whose titles are
+// shown too.
+func (cache *urgentCache) contains(window xproto.Window) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for _, info := range cache.windows {
+		if info.ID == uint64(window) {
+			return true
+		}
+	}
+	return false
+}
+
+// atomNames caches the names of atoms, which never change while the display
+// server runs. It is shared between copies of an X11 like focusHistory.
+type atomNames struct {
+	mutex sync.Mutex
+	names map[xproto.Atom]string
 }
 
-// NewX11 starts up a new connection to an X11 display server, interning all
-// necessary atoms up front and setting up the root window.
+// NewX11 starts up a new connection to the X11 display server named by
+// $DISPLAY, interning all necessary atoms up front and setting up the root
+// window.
 func NewX11() (*X11, error) {
-	xConnection, err := xgb.NewConn()
+	return NewX11ForDisplay("")
+}
+
+// NewX11ForDisplay starts up a new connection to the given X11 display server,
+// like ":1", interning all necessary atoms up front and setting up the root
+// window. An empty display falls back to $DISPLAY. Errors name the display
+// that failed.
+func NewX11ForDisplay(display string) (*X11, error) {
+	if display == "" {
+		display = os.Getenv("DISPLAY")
+	}
+	x11, err := newX11(display)
+	if err != nil {
+		return nil, fmt.Errorf("X11 display %s: %w", display, err)
+	}
+	return x11, nil
+}
+
+func newX11(display string) (*X11, error) {
+	xConnection, err := xgb.NewConnDisplay(display)
 	if err != nil {
 		return nil, err
 	}
@@ -78,13 +242,67 @@ func NewX11() (*X11, error) {
 		return nil, err
 	}
 
+	windowTypeAtom, err := fetchAtom(xConnection, "_NET_WM_WINDOW_TYPE")
+	if err != nil {
+		return nil, err
+	}
+
+	windowRoleAtom, err := fetchAtom(xConnection, "WM_WINDOW_ROLE")
+	if err != nil {
+		return nil, err
+	}
+
+	windowPIDAtom, err := fetchAtom(xConnection, "_NET_WM_PID")
+	if err != nil {
+		return nil, err
+	}
+
+	clientListAtom, err := fetchAtom(xConnection, "_NET_CLIENT_LIST")
+	if err != nil {
+		return nil, err
+	}
+
+	windowStateAtom, err := fetchAtom(xConnection, "_NET_WM_STATE")
+	if err != nil {
+		return nil, err
+	}
+
+	demandsAttentionAtom, err := fetchAtom(xConnection, "_NET_WM_STATE_DEMANDS_ATTENTION")
+	if err != nil {
+		return nil, err
+	}
+
+	currentDesktopAtom, err := fetchAtom(xConnection, "_NET_CURRENT_DESKTOP")
+	if err != nil {
+		return nil, err
+	}
+
+	desktopNamesAtom, err := fetchAtom(xConnection, "_NET_DESKTOP_NAMES")
+	if err != nil {
+		return nil, err
+	}
+
 	return &X11{
 		XConnection:      xConnection,
+		Display:          display,
 		RootWindow:       rootWindow,
 		ActiveWindowAtom: *activeWindowAtom,
 		WindowNameAtom:   *windowNameAtom,
 		WindowName2Atom:  *windowName2Atom,
 		WindowName3Atom:  *windowName3Atom,
+		WindowTypeAtom:   *windowTypeAtom,
+		WindowRoleAtom:   *windowRoleAtom,
+		WindowPIDAtom:    *windowPIDAtom,
+
+		ClientListAtom:       *clientListAtom,
+		WindowStateAtom:      *windowStateAtom,
+		DemandsAttentionAtom: *demandsAttentionAtom,
+
+		CurrentDesktopAtom: *currentDesktopAtom,
+		DesktopNamesAtom:   *desktopNamesAtom,
+
+		stateNames: &atomNames{names: map[xproto.Atom]string{}},
+		urgent:     &urgentCache{},
 	}, nil
 }
 
@@ -96,14 +314,30 @@ func (x11 X11) activeWindow() (*xproto.Window, error) {
 	if err != nil {
 		return nil, err
 	}
-	// Value can be empty (like right after X11 starts), handle that and other cases
+	// Value can be empty (like right after X11 starts or on an empty
+	// workspace), handle that and other cases
+	if len(reply.Value) == 0 {
+		return nil, ErrNoActiveWindow
+	}
 	if len(reply.Value) != 4 {
 		return nil, ErrInvalidReply
 	}
 	window := xproto.Window(xgb.Get32(reply.Value))
+	if window == xproto.WindowNone {
+		return nil, ErrNoActiveWindow
+	}
 	return &window, nil
 }
 
+// Get the xproto.Window to report as active, which is the most recently active
+// one on the configured output when there is one.
+func (x11 X11) reportedWindow() (*xproto.Window, error) {
+	if x11.Output != "" {
+		return x11.activeWindowOnOutput()
+	}
+	return x11.activeWindow()
+}
+
 // Get the title attribute as a string of the given xproto.Window.
 func (x11 X11) windowTitleProperty(window xproto.Window) (*string, error) {
 	// Now get the value of _NET_WM_NAME for the active window.
@@ -116,6 +350,178 @@ func (x11 X11) windowTitleProperty(window xproto.Window) (*string, error) {
 	return &title, nil
 }
 
+// Get the instance and class parts of the WM_CLASS attribute of the given
+// xproto.Window.
+func (x11 X11) windowClassProperty(window xproto.Window) (string, string, error) {
+	reply, err := xproto.GetProperty(x11.XConnection, false, window, xproto.AtomWmClass,
+		xproto.GetPropertyTypeAny, 0, (1<<32)-1).Reply()
+	if err != nil {
+		return "", "", err
+	}
+	// WM_CLASS is two consecutive null-terminated strings, instance then class
+	parts := strings.Split(strings.TrimRight(string(reply.Value), "\x00"), "\x00")
+	if len(parts) < 2 {
+		return parts[0], "", nil
+	}
+	return parts[0], parts[1], nil
+}
+
+// Get the window types of the given xproto.Window from _NET_WM_WINDOW_TYPE,
+// trimmed down to the lower case part after the common prefix, like "dialog".
+func (x11 X11) windowTypesProperty(window xproto.Window) ([]string, error) {
+	if x11.WindowTypeAtom == xproto.AtomNone {
+		// nobody ever set a window type on this display
+		return nil, nil
+	}
+	reply, err := xproto.GetProperty(x11.XConnection, false, window, x11.WindowTypeAtom,
+		xproto.AtomAtom, 0, (1<<32)-1).Reply()
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	for value := reply.Value; len(value) >= 4; value = value[4:] {
+		name, err := xproto.GetAtomName(x11.XConnection, xproto.Atom(xgb.Get32(value))).Reply()
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, strings.ToLower(strings.TrimPrefix(name.Name, "_NET_WM_WINDOW_TYPE_")))
+	}
+	return types, nil
+}
+
+// Get the WM_WINDOW_ROLE attribute of the given xproto.Window.
+func (x11 X11) windowRoleProperty(window xproto.Window) (string, error) {
+	if x11.WindowRoleAtom == xproto.AtomNone {
+		// nobody ever set a window role on this display
+		return "", nil
+	}
+	reply, err := xproto.GetProperty(x11.XConnection, false, window, x11.WindowRoleAtom,
+		xproto.GetPropertyTypeAny, 0, (1<<32)-1).Reply()
+	if err != nil {
+		return "", err
+	}
+	return string(reply.Value), nil
+}
+
+// Get the _NET_WM_PID attribute of the given xproto.Window, 0 when unknown.
+func (x11 X11) windowPIDProperty(window xproto.Window) (int, error) {
+	if x11.WindowPIDAtom == xproto.AtomNone {
+		// nobody ever set a window pid on this display
+		return 0, nil
+	}
+	reply, err := xproto.GetProperty(x11.XConnection, false, window, x11.WindowPIDAtom,
+		xproto.AtomCardinal, 0, 1).Reply()
+	if err != nil {
+		return 0, err
+	}
+	if len(reply.Value) != 4 {
+		return 0, nil
+	}
+	return int(xgb.Get32(reply.Value)), nil
+}
+
+// Get the _NET_WM_STATE atoms of the given xproto.Window.
+func (x11 X11) windowStateProperty(window xproto.Window) ([]xproto.Atom, error) {
+	if x11.WindowStateAtom == xproto.AtomNone {
+		// nobody ever set a window state on this display
+		return nil, nil
+	}
+	reply, err := xproto.GetProperty(x11.XConnection, false, window, x11.WindowStateAtom,
+		xproto.AtomAtom, 0, (1<<32)-1).Reply()
+	if err != nil {
+		return nil, err
+	}
+	var states []xproto.Atom
+	for value := reply.Value; len(value) >= 4; value = value[4:] {
+		states = append(states, xproto.Atom(xgb.Get32(value)))
+	}
+	return states, nil
+}
+
+// Get the window states of the given xproto.Window from _NET_WM_STATE, trimmed
+// down to the lower case part after the common prefix, like "fullscreen".
+func (x11 X11) windowStatesProperty(window xproto.Window) ([]string, error) {
+	atoms, err := x11.windowStateProperty(window)
+	if err != nil {
+		return nil, err
+	}
+	var states []string
+	for _, atom := range atoms {
+		name, err := x11.stateName(atom)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, strings.ToLower(strings.TrimPrefix(name, "_NET_WM_STATE_")))
+	}
+	return states, nil
+}
+
+// Get the name of the given window state atom, only asking the display server
+// the first time.
+func (x11 X11) stateName(atom xproto.Atom) (string, error) {
+	if x11.stateNames != nil {
+		x11.stateNames.mutex.Lock()
+		defer x11.stateNames.mutex.Unlock()
+		if name, found := x11.stateNames.names[atom]; found {
+			return name, nil
+		}
+	}
+	reply, err := xproto.GetAtomName(x11.XConnection, atom).Reply()
+	if err != nil {
+		return "", err
+	}
+	if x11.stateNames != nil {
+		x11.stateNames.names[atom] = reply.Name
+	}
+	return reply.Name, nil
+}
+
+// Report whether the given xproto.Window demands attention, either through the
+// urgency hint of WM_HINTS or through _NET_WM_STATE_DEMANDS_ATTENTION.
+func (x11 X11) windowUrgent(window xproto.Window) (bool, error) {
+	reply, err := xproto.GetProperty(x11.XConnection, false, window, xproto.AtomWmHints,
+		xproto.AtomWmHints, 0, 1).Reply()
+	if err != nil {
+		return false, err
+	}
+	// the first field of WM_HINTS holds the flags, the urgency hint is bit 8
+	if len(reply.Value) >= 4 && xgb.Get32(reply.Value)&(1<<8) != 0 {
+		return true, nil
+	}
+
+	if x11.DemandsAttentionAtom == xproto.AtomNone {
+		return false, nil
+	}
+	states, err := x11.windowStateProperty(window)
+	if err != nil {
+		return false, err
+	}
+	for _, state := range states {
+		if state == x11.DemandsAttentionAtom {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Get all windows managed by the window manager from _NET_CLIENT_LIST.
+func (x11 X11) clientList() ([]xproto.Window, error) {
+	if x11.ClientListAtom == xproto.AtomNone {
+		// the window manager never listed its windows on this display
+		return nil, nil
+	}
+	reply, err := xproto.GetProperty(x11.XConnection, false, x11.RootWindow, x11.ClientListAtom,
+		xproto.AtomWindow, 0, (1<<32)-1).Reply()
+	if err != nil {
+		return nil, err
+	}
+	var windows []xproto.Window
+	for value := reply.Value; len(value) >= 4; value = value[4:] {
+		windows = append(windows, xproto.Window(xgb.Get32(value)))
+	}
+	return windows, nil
+}
+
 // Subscribe the current XConnection to change events in window attributes (like
 // the title attribute) for the given xproto.Window.
 func (x11 X11) subscribeToWindowChangeEvents(window xproto.Window) {
@@ -129,26 +535,129 @@ func (x11 X11) subscribeToWindowChangeEvents(window xproto.Window) {
 // ActiveWindowTitle returns the currently active window title or an empty
 // string if one is not available.
 func (x11 X11) ActiveWindowTitle() string {
-	activeWindow, err := x11.activeWindow()
+	activeWindow, err := x11.reportedWindow()
 	if err != nil {
 		// no title on error
 		return ""
 	}
 
+	return x11.windowTitle(*activeWindow)
+}
+
+// ActiveWindow returns the id, title, WM_CLASS, window types, role, pid,
+// urgency and window states of the currently active window.
+func (x11 X11) ActiveWindow() (Info, error) {
+	activeWindow, err := x11.reportedWindow()
+	if err != nil {
+		return Info{}, err
+	}
+
 	windowTitle, err := x11.windowTitleProperty(*activeWindow)
 	if err != nil {
-		// no title on error
-		return ""
+		return Info{}, err
 	}
-	return *windowTitle
+
+	// a missing class, type, role or pid is not worth failing over
+	instance, class, _ := x11.windowClassProperty(*activeWindow)
+	types, _ := x11.windowTypesProperty(*activeWindow)
+	role, _ := x11.windowRoleProperty(*activeWindow)
+	pid, _ := x11.windowPIDProperty(*activeWindow)
+	urgent, _ := x11.windowUrgent(*activeWindow)
+	states, _ := x11.windowStatesProperty(*activeWindow)
+	return Info{
+		ID:       uint64(*activeWindow),
+		Title:    *windowTitle,
+		Class:    class,
+		Instance: instance,
+		Types:    types,
+		Role:     role,
+		PID:      pid,
+		Urgent:   urgent,
+		States:   states,
+	}, nil
+}
+
+// UrgentWindows returns the id, title and WM_CLASS of every window in
+// _NET_CLIENT_LIST that demands attention. While DetectWindowTitleChanges
+// runs, they're only looked up again after an event that may change them.
+func (x11 X11) UrgentWindows() ([]Info, error) {
+	if x11.urgent == nil {
+		return x11.urgentWindows()
+	}
+	return x11.urgent.get(x11.urgentWindows)
+}
+
+// Look up every window in _NET_CLIENT_LIST that demands attention.
+func (x11 X11) urgentWindows() ([]Info, error) {
+	windows, err := x11.clientList()
+	if err != nil {
+		return nil, err
+	}
+	var urgentWindows []Info
+	for _, window := range windows {
+		// windows can go away at any moment, skip those
+		urgent, err := x11.windowUrgent(window)
+		if err != nil || !urgent {
+			continue
+		}
+		instance, class, _ := x11.windowClassProperty(window)
+		urgentWindows = append(urgentWindows, Info{
+			ID:       uint64(window),
+			Title:    x11.windowTitle(window),
+			Class:    class,
+			Instance: instance,
+			Urgent:   true,
+		})
+	}
+	return urgentWindows, nil
+}
+
+// CurrentWorkspace returns the name of the current desktop from
+// _NET_DESKTOP_NAMES, or its number counting from 1 when it has no name.
+func (x11 X11) CurrentWorkspace() (string, error) {
+	if x11.CurrentDesktopAtom == xproto.AtomNone {
+		// the window manager never set a current desktop on this display
+		return "", ErrNoWorkspaces
+	}
+	reply, err := xproto.GetProperty(x11.XConnection, false, x11.RootWindow, x11.CurrentDesktopAtom,
+		xproto.AtomCardinal, 0, 1).Reply()
+	if err != nil {
+		return "", err
+	}
+	if len(reply.Value) != 4 {
+		return "", ErrNoWorkspaces
+	}
+	desktop := int(xgb.Get32(reply.Value))
+
+	if x11.DesktopNamesAtom != xproto.AtomNone {
+		reply, err = xproto.GetProperty(x11.XConnection, false, x11.RootWindow, x11.DesktopNamesAtom,
+			xproto.GetPropertyTypeAny, 0, (1<<32)-1).Reply()
+		if err != nil {
+			return "", err
+		}
+		// the names are consecutive null-terminated strings
+		names := strings.Split(string(reply.Value), "\x00")
+		if desktop < len(names) && names[desktop] != "" {
+			return names[desktop], nil
+		}
+	}
+	return strconv.Itoa(desktop + 1), nil
 }
 
 // DetectWindowTitleChanges blocks and starts detecting changes in window
-// titles. When a change is detected, the onChange function is called and when a
-// non-fatal error occurs the onError function is called for that error.
-func (x11 X11) DetectWindowTitleChanges(onChange func(), onError func(error)) error {
+// titles. When a change is detected, the onChange function is called with an
+// Event describing it and when a non-fatal error occurs the onError function is
+// called for that error.
+func (x11 X11) DetectWindowTitleChanges(onChange func(Event), onError func(error)) error {
 	// Subscribe to events from the root window.
 	x11.subscribeToWindowChangeEvents(x11.RootWindow)
+	if x11.urgent != nil {
+		x11.urgent.watch()
+	}
+
+	// Subscribe to every managed window as well, any of them can demand
+	// attention.
+	x11.subscribeToClients(func(Event) {}, onError)
 
 	// TODO Refactor this infinite loop when xgb supports a clean shut down.
 
@@ -162,7 +671,7 @@ func (x11 X11) DetectWindowTitleChanges(onChange func(), onError func(error)) er
 		// request.
 		ev, xerr := x11.XConnection.WaitForEvent()
 		if ev == nil && xerr == nil {
-			err := errors.New("Both event and error are nil from XConnection, exiting X11 event loop")
+			err := fmt.Errorf("Both event and error are nil from XConnection for display %s, exiting X11 event loop", x11.Display)
 			onError(err)
 			return err
 		}
@@ -173,22 +682,65 @@ func (x11 X11) DetectWindowTitleChanges(onChange func(), onError func(error)) er
 			case xproto.PropertyNotifyEvent:
 				switch v.Atom {
 				case x11.WindowNameAtom, x11.WindowName2Atom, x11.WindowName3Atom:
-					onChange()
+					if x11.urgent != nil && x11.urgent.contains(v.Window) {
+						x11.invalidateUrgent()
+					}
+					onChange(x11.titleChangedEvent(v.Window))
+				case xproto.AtomWmHints, x11.WindowStateAtom:
+					x11.invalidateUrgent()
+					if v.Atom == x11.WindowStateAtom {
+						onChange(Event{Type: StateChanged, WindowID: uint64(v.Window), Title: x11.windowTitle(v.Window)})
+					}
+					urgent, err := x11.windowUrgent(v.Window)
+					if err != nil {
+						onError(err)
+						continue
+					}
+					onChange(x11.urgencyChangedEvent(v.Window, urgent))
+				case x11.ClientListAtom:
+					if v.Window == x11.RootWindow {
+						x11.invalidateUrgent()
+						x11.subscribeToClients(onChange, onError)
+					}
+				case x11.CurrentDesktopAtom, x11.DesktopNamesAtom:
+					if v.Window != x11.RootWindow {
+						continue
+					}
+					workspace, err := x11.CurrentWorkspace()
+					if err != nil {
+						onError(err)
+						continue
+					}
+					onChange(Event{Type: WorkspaceChanged, Title: workspace})
 				case x11.ActiveWindowAtom:
-					onChange()
-
 					// Subscribe to events of all windows as they are activated.
 					// This is the trick to get complex windows that change
 					// their titles as tabs are activated to be detected.
 					activeWindow, err := x11.activeWindow()
 					if err != nil {
+						onChange(Event{Type: FocusChanged})
 						onError(err)
+						continue
+					}
+					x11.subscribeToWindowChangeEvents(*activeWindow)
+
+					// With an output, the reported window may be a different
+					// one that was focused earlier.
+					reportedWindow, err := x11.reportedWindow()
+					if err != nil {
+						onChange(Event{Type: FocusChanged})
 					} else {
-						x11.subscribeToWindowChangeEvents(*activeWindow)
+						onChange(x11.focusChangedEvent(*reportedWindow))
 					}
 				default:
 					// Ignore everything else.
 				}
+			case xproto.DestroyNotifyEvent:
+				if x11.focusHistory != nil {
+					x11.focusHistory.remove(v.Window)
+				}
+				x11.invalidateUrgent()
+				onChange(Event{Type: WindowClosed, WindowID: uint64(v.Window)})
 			}
 		}
 
@@ -199,6 +751,53 @@ func (x11 X11) DetectWindowTitleChanges(onChange func(), onError func(error)) er
 	}
 }
 
+// Forget the cached windows demanding attention, if any.
+func (x11 X11) invalidateUrgent() {
+	if x11.urgent != nil {
+		x11.urgent.invalidate()
+	}
+}
+
+// Build a FocusChanged Event for the given newly active xproto.Window.
+func (x11 X11) focusChangedEvent(window xproto.Window) Event {
+	return Event{Type: FocusChanged, WindowID: uint64(window), Title: x11.windowTitle(window)}
+}
+
+// Build an UrgencyChanged Event for the given xproto.Window.
+func (x11 X11) urgencyChangedEvent(window xproto.Window, urgent bool) Event {
+	return Event{Type: UrgencyChanged, WindowID: uint64(window), Title: x11.windowTitle(window), Urgent: urgent}
+}
+
+// Subscribe to change events of every window in _NET_CLIENT_LIST, calling the
+// onChange function for those that already demand attention as they show up.
+func (x11 X11) subscribeToClients(onChange func(Event), onError func(error)) {
+	windows, err := x11.clientList()
+	if err != nil {
+		onError(err)
+		return
+	}
+	for _, window := range windows {
+		x11.subscribeToWindowChangeEvents(window)
+		if urgent, err := x11.windowUrgent(window); err == nil && urgent {
+			onChange(x11.urgencyChangedEvent(window, true))
+		}
+	}
+}
+
+// Build a TitleChanged Event for the given xproto.Window.
+func (x11 X11) titleChangedEvent(window xproto.Window) Event {
+	return Event{Type: TitleChanged, WindowID: uint64(window), Title: x11.windowTitle(window)}
+}
+
+// Get the title of the given xproto.Window or an empty string on error.
+func (x11 X11) windowTitle(window xproto.Window) string {
+	windowTitle, err := x11.windowTitleProperty(window)
+	if err != nil {
+		return ""
+	}
+	return *windowTitle
+}
+
 // Get the atom id (i.e., intern an atom) of the given name.
 func fetchAtom(c *xgb.Conn, name string) (*xproto.Atom, error) {
 	cookie, err := xproto.InternAtom(c, true, uint16(len(name)), name).Reply()
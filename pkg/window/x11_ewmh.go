@@ -0,0 +1,124 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"errors"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// ErrNoWindowManager is returned when no EWMH compliant window manager
+// announces itself on the display.
+var ErrNoWindowManager = errors.New("no EWMH window manager announces itself in _NET_SUPPORTING_WM_CHECK")
+
+// WindowManager returns the name of the EWMH compliant window manager running
+// on the display, which announces itself with a window named after it in
+// _NET_SUPPORTING_WM_CHECK on the root window. The name is empty when that
+// window has none.
+func (x11 X11) WindowManager() (string, error) {
+	checkAtom, err := fetchAtom(x11.XConnection, "_NET_SUPPORTING_WM_CHECK")
+	if err != nil {
+		return "", err
+	}
+	if *checkAtom == xproto.AtomNone {
+		return "", ErrNoWindowManager
+	}
+	reply, err := xproto.GetProperty(x11.XConnection, false, x11.RootWindow, *checkAtom,
+		xproto.AtomWindow, 0, 1).Reply()
+	if err != nil {
+		return "", err
+	}
+	if len(reply.Value) != 4 {
+		return "", ErrNoWindowManager
+	}
+	check := xproto.Window(xgb.Get32(reply.Value))
+
+	// older window managers only set the legacy name
+	for _, nameAtom := range []xproto.Atom{x11.WindowNameAtom, xproto.AtomWmName} {
+		if nameAtom == xproto.AtomNone {
+			continue
+		}
+		reply, err := xproto.GetProperty(x11.XConnection, false, check, nameAtom,
+			xproto.GetPropertyTypeAny, 0, (1<<32)-1).Reply()
+		if err != nil {
+			return "", err
+		}
+		if len(reply.Value) > 0 {
+			return string(reply.Value), nil
+		}
+	}
+	return "", nil
+}
+
+// SupportedAtoms returns the names of the hints the window manager claims to
+// support in _NET_SUPPORTED on the root window, none without it.
+func (x11 X11) SupportedAtoms() ([]string, error) {
+	supportedAtom, err := fetchAtom(x11.XConnection, "_NET_SUPPORTED")
+	if err != nil || *supportedAtom == xproto.AtomNone {
+		return nil, err
+	}
+	reply, err := xproto.GetProperty(x11.XConnection, false, x11.RootWindow, *supportedAtom,
+		xproto.AtomAtom, 0, (1<<32)-1).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	// ask for every name before waiting for the first one
+	var cookies []xproto.GetAtomNameCookie
+	for i := 0; i+4 <= len(reply.Value); i += 4 {
+		atom := xproto.Atom(xgb.Get32(reply.Value[i:]))
+		cookies = append(cookies, xproto.GetAtomName(x11.XConnection, atom))
+	}
+	var names []string
+	for _, cookie := range cookies {
+		nameReply, err := cookie.Reply()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, nameReply.Name)
+	}
+	return names, nil
+}
+
+// MissingAtoms returns the names of the atoms the x11 backend uses that don't
+// exist on the display, since no window manager or application set them yet.
+// What needs them doesn't work, like the title without _NET_ACTIVE_WINDOW or
+// workspaces without _NET_CURRENT_DESKTOP.
+func (x11 X11) MissingAtoms() []string {
+	atoms := []struct {
+		name string
+		atom xproto.Atom
+	}{
+		{"_NET_ACTIVE_WINDOW", x11.ActiveWindowAtom},
+		{"_NET_WM_NAME", x11.WindowNameAtom},
+		{"_NET_WM_WINDOW_TYPE", x11.WindowTypeAtom},
+		{"WM_WINDOW_ROLE", x11.WindowRoleAtom},
+		{"_NET_WM_PID", x11.WindowPIDAtom},
+		{"_NET_CLIENT_LIST", x11.ClientListAtom},
+		{"_NET_WM_STATE", x11.WindowStateAtom},
+		{"_NET_WM_STATE_DEMANDS_ATTENTION", x11.DemandsAttentionAtom},
+		{"_NET_CURRENT_DESKTOP", x11.CurrentDesktopAtom},
+		{"_NET_DESKTOP_NAMES", x11.DesktopNamesAtom},
+	}
+	var missing []string
+	for _, atom := range atoms {
+		if atom.atom == xproto.AtomNone {
+			missing = append(missing, atom.name)
+		}
+	}
+	return missing
+}
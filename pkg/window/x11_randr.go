@@ -0,0 +1,164 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// Only this many recently focused windows are remembered per output.
+const focusHistorySize = 32
+
+// focusHistory remembers recently focused windows, most recent first. It is
+// shared between copies of an X11 since the event loop and the status line
+// loop run in different goroutines.
+type focusHistory struct {
+	mutex   sync.Mutex
+	windows []xproto.Window
+}
+
+// Move the given window to the front of the history.
+func (history *focusHistory) push(window xproto.Window) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	if len(history.windows) > 0 && history.windows[0] == window {
+		return
+	}
+	windows := []xproto.Window{window}
+	for _, w := range history.windows {
+		if w != window && len(windows) < focusHistorySize {
+			windows = append(windows, w)
+		}
+	}
+	history.windows = windows
+}
+
+// Forget a window that went away.
+func (history *focusHistory) remove(window xproto.Window) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	for i, w := range history.windows {
+		if w == window {
+			history.windows = append(history.windows[:i:i], history.windows[i+1:]...)
+			return
+		}
+	}
+}
+
+func (history *focusHistory) snapshot() []xproto.Window {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	return append([]xproto.Window(nil), history.windows...)
+}
+
+// rect is an area of the root window.
+type rect struct {
+	x, y          int
+	width, height int
+}
+
+// Report whether the given point lies inside the rect.
+func (r rect) contains(x int, y int) bool {
+	return x >= r.x && x < r.x+r.width && y >= r.y && y < r.y+r.height
+}
+
+// Restrict this X11 to the given RandR output, like "DP-1", failing if it
+// doesn't exist. When the active window is on another output, the most
+// recently focused window on this one is reported instead.
+func (x11 *X11) watchOutput(output string) error {
+	if err := randr.Init(x11.XConnection); err != nil {
+		return fmt.Errorf("X11 display %s: RandR is not available for output %s: %w", x11.Display, output, err)
+	}
+	x11.Output = output
+	x11.focusHistory = &focusHistory{}
+	if _, err := x11.outputRect(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Get the current area of the root window covered by the configured output.
+// This is looked up every time since outputs come and go.
+func (x11 X11) outputRect() (*rect, error) {
+	resources, err := randr.GetScreenResourcesCurrent(x11.XConnection, x11.RootWindow).Reply()
+	if err != nil {
+		return nil, err
+	}
+	for _, output := range resources.Outputs {
+		outputInfo, err := randr.GetOutputInfo(x11.XConnection, output, resources.ConfigTimestamp).Reply()
+		if err != nil {
+			return nil, err
+		}
+		if string(outputInfo.Name) != x11.Output {
+			continue
+		}
+		if outputInfo.Crtc == 0 {
+			return nil, fmt.Errorf("X11 display %s: RandR output %s is disabled", x11.Display, x11.Output)
+		}
+		crtcInfo, err := randr.GetCrtcInfo(x11.XConnection, outputInfo.Crtc, resources.ConfigTimestamp).Reply()
+		if err != nil {
+			return nil, err
+		}
+		return &rect{int(crtcInfo.X), int(crtcInfo.Y), int(crtcInfo.Width), int(crtcInfo.Height)}, nil
+	}
+	return nil, fmt.Errorf("X11 display %s: RandR output %s does not exist", x11.Display, x11.Output)
+}
+
+// Report whether the center of the given xproto.Window lies on the given rect.
+func (x11 X11) windowOnRect(window xproto.Window, area *rect) (bool, error) {
+	geometry, err := xproto.GetGeometry(x11.XConnection, xproto.Drawable(window)).Reply()
+	if err != nil {
+		return false, err
+	}
+	// geometry is relative to the parent, which is a frame when reparented
+	position, err := xproto.TranslateCoordinates(x11.XConnection, window, x11.RootWindow, 0, 0).Reply()
+	if err != nil {
+		return false, err
+	}
+	centerX := int(position.DstX) + int(geometry.Width)/2
+	centerY := int(position.DstY) + int(geometry.Height)/2
+	return area.contains(centerX, centerY), nil
+}
+
+// Get the most recently focused xproto.Window on the configured output,
+// starting with the currently active one.
+func (x11 X11) activeWindowOnOutput() (*xproto.Window, error) {
+	if activeWindow, err := x11.activeWindow(); err == nil {
+		x11.focusHistory.push(*activeWindow)
+	}
+
+	area, err := x11.outputRect()
+	if err != nil {
+		return nil, err
+	}
+	for _, window := range x11.focusHistory.snapshot() {
+		onOutput, err := x11.windowOnRect(window, area)
+		if err != nil {
+			// most likely closed since it was focused
+			x11.focusHistory.remove(window)
+			continue
+		}
+		if onOutput {
+			return &window, nil
+		}
+	}
+	return nil, ErrNoActiveWindow
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+func TestFocusHistoryOrder(t *testing.T) {
+	history := &focusHistory{}
+	history.push(1)
+	history.push(2)
+	history.push(3)
+	history.push(1)
+	history.push(1)
+	history.remove(3)
+	history.remove(42)
+
+	windows := history.snapshot()
+	expected := []xproto.Window{1, 2}
+	if len(windows) != len(expected) {
+		t.Fatalf("Unexpected history %v", windows)
+	}
+	for i := range expected {
+		if windows[i] != expected[i] {
+			t.Fatalf("Unexpected history %v", windows)
+		}
+	}
+}
+
+func TestFocusHistoryLimit(t *testing.T) {
+	history := &focusHistory{}
+	for i := 0; i < focusHistorySize*2; i++ {
+		history.push(xproto.Window(i))
+	}
+	windows := history.snapshot()
+	if len(windows) != focusHistorySize {
+		t.Fatalf("Unexpected history size %d", len(windows))
+	}
+	if windows[0] != xproto.Window(focusHistorySize*2-1) {
+		t.Fatal("Expected most recent window first")
+	}
+}
+
+func TestRectContains(t *testing.T) {
+	right := rect{1920, 0, 2560, 1440}
+	if !right.contains(1920, 0) || !right.contains(4479, 1439) {
+		t.Fatal("Expected edges to be inside")
+	}
+	if right.contains(1919, 10) || right.contains(4480, 10) || right.contains(2000, 1440) {
+		t.Fatal("Expected points outside")
+	}
+}
+
+// Get the name of the first RandR output of the given display, which Xvfb
+// calls "screen" and covers its whole screen.
+func firstOutput(t *testing.T, display string) string {
+	conn, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := randr.Init(conn); err != nil {
+		t.Skipf("RandR is not available: %v", err)
+	}
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+	resources, err := randr.GetScreenResourcesCurrent(conn, root).Reply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources.Outputs) == 0 {
+		t.Skip("Xvfb has no RandR outputs")
+	}
+	outputInfo, err := randr.GetOutputInfo(conn, resources.Outputs[0], resources.ConfigTimestamp).Reply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(outputInfo.Name)
+}
+
+func TestX11ActiveWindowOnOutput(t *testing.T) {
+	display := startXvfb(t)
+	client := newEWMHClient(t, display)
+	output := firstOutput(t, display)
+	editor := client.createWindow("alacritty", "Alacritty", "vim main.go")
+	// the screen is 640x480, this one is centered off of it
+	offscreen := client.createWindow("Navigator", "firefox", "Mozilla Firefox")
+	client.move(offscreen, 1000, 1000)
+	client.activate(editor)
+
+	backend, _ := DefaultRegistry.Lookup("x11")
+	api, err := backend.Open(Options{Display: display, Output: output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x11 := api.(*X11)
+	info, err := x11.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != uint64(editor) {
+		t.Fatalf("Expected the editor on %s, got %+v", output, info)
+	}
+
+	// focusing a window elsewhere keeps the last one focused on the output
+	client.activate(offscreen)
+	info, err = x11.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != uint64(editor) || info.Title != "vim main.go" {
+		t.Fatalf("Expected the editor to stay on %s, got %+v", output, info)
+	}
+
+	// once it's gone, nothing focused is left on the output
+	client.destroy(editor)
+	if _, err := x11.ActiveWindow(); err != ErrNoActiveWindow {
+		t.Fatalf("Expected no active window on %s, got %v", output, err)
+	}
+}
+
+func TestX11UnknownOutput(t *testing.T) {
+	display := startXvfb(t)
+	newEWMHClient(t, display)
+
+	backend, _ := DefaultRegistry.Lookup("x11")
+	_, err := backend.Open(Options{Display: display, Output: "potato"})
+	if err == nil || !strings.Contains(err.Error(), "RandR output potato does not exist") {
+		t.Fatalf("Expected the missing output to be named, got %v", err)
+	}
+}
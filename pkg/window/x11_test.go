@@ -0,0 +1,603 @@
+// Copyright 2019 Ray Holder
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// These tests run against a throwaway Xvfb server and are skipped when Xvfb is
+// not installed. The ewmhClient plays the part of both the applications and
+// the window manager, creating windows and setting the properties a real
+// EWMH compliant window manager would.
+
+// Start a new Xvfb server, point DISPLAY at it and return the display name.
+func startXvfb(t *testing.T) string {
+	xvfb, err := exec.LookPath("Xvfb")
+	if err != nil {
+		t.Skip("Xvfb is not installed, skipping X11 integration test")
+	}
+
+	// Xvfb picks a free display and writes its number to the given fd.
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	cmd := exec.Command(xvfb, "-displayfd", "3", "-screen", "0", "640x480x24", "-nolisten", "tcp")
+	cmd.ExtraFiles = []*os.File{writer}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	line, err := bufio.NewReader(reader).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Xvfb did not report a display: %v", err)
+	}
+	display := ":" + strings.TrimSpace(line)
+	t.Setenv("DISPLAY", display)
+	return display
+}
+
+// ewmhClient is a tiny X11 client that sets window properties the way
+// applications and an EWMH window manager would.
+type ewmhClient struct {
+	t    *testing.T
+	conn *xgb.Conn
+	root xproto.Window
+}
+
+func newEWMHClient(t *testing.T, display string) *ewmhClient {
+	conn, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	client := &ewmhClient{t, conn, xproto.Setup(conn).DefaultScreen(conn).Root}
+
+	// NewX11 only looks up atoms that already exist, which on a bare server
+	// without a window manager they don't.
+	atoms := []string{"_NET_ACTIVE_WINDOW", "_NET_WM_NAME", "_WM_NAME", "UTF8_STRING",
+		"_NET_CURRENT_DESKTOP", "_NET_DESKTOP_NAMES"}
+	for _, name := range atoms {
+		client.atom(name)
+	}
+	return client
+}
+
+func (client *ewmhClient) atom(name string) xproto.Atom {
+	reply, err := xproto.InternAtom(client.conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+	return reply.Atom
+}
+
+// Create a new window with the given class and titles.
+func (client *ewmhClient) createWindow(instance string, class string, title string) xproto.Window {
+	window, err := xproto.NewWindowId(client.conn)
+	if err != nil {
+		client.t.Fatal(err)
+	}
+	// a depth and visual of 0 copy them from the root window
+	err = xproto.CreateWindowChecked(client.conn, 0, window, client.root,
+		0, 0, 100, 100, 0, xproto.WindowClassInputOutput, 0, 0, nil).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+	wmClass := instance + "\x00" + class + "\x00"
+	client.setProperty(window, xproto.AtomWmClass, xproto.AtomString, wmClass)
+	client.setTitle(window, title)
+	return window
+}
+
+// Set both the EWMH and the legacy title of a window.
+func (client *ewmhClient) setTitle(window xproto.Window, title string) {
+	client.setProperty(window, client.atom("_NET_WM_NAME"), client.atom("UTF8_STRING"), title)
+	client.setProperty(window, xproto.AtomWmName, xproto.AtomString, title)
+}
+
+// Set only the legacy title of a window, like older applications do.
+func (client *ewmhClient) setLegacyTitle(window xproto.Window, title string) {
+	client.setProperty(window, xproto.AtomWmName, xproto.AtomString, title)
+}
+
+// Set the process id owning a window, like toolkits do.
+func (client *ewmhClient) setPID(window xproto.Window, pid uint32) {
+	value := make([]byte, 4)
+	xgb.Put32(value, pid)
+	err := xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, window,
+		client.atom("_NET_WM_PID"), xproto.AtomCardinal, 32, 1, value).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+// List the given windows as managed, like a window manager would.
+func (client *ewmhClient) setClientList(windows ...xproto.Window) {
+	value := make([]byte, 4*len(windows))
+	for i, window := range windows {
+		xgb.Put32(value[4*i:], uint32(window))
+	}
+	err := xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, client.root,
+		client.atom("_NET_CLIENT_LIST"), xproto.AtomWindow, 32, uint32(len(windows)), value).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+// Set or clear the urgency hint in WM_HINTS of a window, like applications do.
+func (client *ewmhClient) setUrgencyHint(window xproto.Window, urgent bool) {
+	// flags, input, initial state, icon pixmap, icon window, icon x and y, icon
+	// mask and window group
+	value := make([]byte, 4*9)
+	if urgent {
+		xgb.Put32(value, 1<<8)
+	}
+	err := xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, window,
+		xproto.AtomWmHints, xproto.AtomWmHints, 32, 9, value).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+// Mark a window as demanding attention through _NET_WM_STATE, like a window
+// manager would.
+func (client *ewmhClient) demandAttention(window xproto.Window) {
+	client.setStates(window, "_NET_WM_STATE_DEMANDS_ATTENTION")
+}
+
+// Replace the _NET_WM_STATE of a window, like a window manager would.
+func (client *ewmhClient) setStates(window xproto.Window, states ...string) {
+	value := make([]byte, 4*len(states))
+	for i, state := range states {
+		xgb.Put32(value[4*i:], uint32(client.atom(state)))
+	}
+	err := xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, window,
+		client.atom("_NET_WM_STATE"), xproto.AtomAtom, 32, uint32(len(states)), value).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+// Mark a window as active on the root window, like a window manager would.
+func (client *ewmhClient) activate(window xproto.Window) {
+	value := make([]byte, 4)
+	xgb.Put32(value, uint32(window))
+	err := xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, client.root,
+		client.atom("_NET_ACTIVE_WINDOW"), xproto.AtomWindow, 32, 1, value).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+// Clear the active window, like switching to an empty workspace does.
+func (client *ewmhClient) deactivate() {
+	err := xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, client.root,
+		client.atom("_NET_ACTIVE_WINDOW"), xproto.AtomWindow, 32, 0, nil).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+// Switch to a desktop by index, naming all desktops, like a window manager
+// would.
+func (client *ewmhClient) switchDesktop(desktop uint32, names ...string) {
+	client.setProperty(client.root, client.atom("_NET_DESKTOP_NAMES"), client.atom("UTF8_STRING"),
+		strings.Join(names, "\x00")+"\x00")
+	value := make([]byte, 4)
+	xgb.Put32(value, desktop)
+	err := xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, client.root,
+		client.atom("_NET_CURRENT_DESKTOP"), xproto.AtomCardinal, 32, 1, value).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+// Move a window so its top left corner is at the given root coordinates.
+func (client *ewmhClient) move(window xproto.Window, x int32, y int32) {
+	err := xproto.ConfigureWindowChecked(client.conn, window, xproto.ConfigWindowX|xproto.ConfigWindowY,
+		[]uint32{uint32(x), uint32(y)}).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+func (client *ewmhClient) destroy(window xproto.Window) {
+	if err := xproto.DestroyWindowChecked(client.conn, window).Check(); err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+func (client *ewmhClient) setProperty(window xproto.Window, property xproto.Atom, propertyType xproto.Atom, value string) {
+	err := xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, window,
+		property, propertyType, 8, uint32(len(value)), []byte(value)).Check()
+	if err != nil {
+		client.t.Fatal(err)
+	}
+}
+
+// Start DetectWindowTitleChanges in the background and return the channel its
+// events are sent to.
+func detectInBackground(t *testing.T, x11 *X11) <-chan Event {
+	// Subscribe and wait for a round trip up front so nothing the test does
+	// next can slip in before the event loop is listening.
+	x11.subscribeToWindowChangeEvents(x11.RootWindow)
+	if _, err := xproto.GetInputFocus(x11.XConnection).Reply(); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan Event, 100)
+	go x11.DetectWindowTitleChanges(func(event Event) {
+		events <- event
+	}, func(err error) {})
+	return events
+}
+
+// Wait for some connection, the event loop being tested, to subscribe to
+// property changes of the given window.
+func (client *ewmhClient) waitForSubscription(window xproto.Window) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reply, err := xproto.GetWindowAttributes(client.conn, window).Reply()
+		if err != nil {
+			client.t.Fatal(err)
+		}
+		if reply.AllEventMasks&xproto.EventMaskPropertyChange != 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			client.t.Fatalf("Timed out waiting for a subscription to window %d", window)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Wait for the next event matching the given type and window.
+func expectEvent(t *testing.T, events <-chan Event, eventType EventType, window xproto.Window) Event {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == eventType && event.WindowID == uint64(window) {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s event on window %d", eventType, window)
+		}
+	}
+}
+
+func TestX11ActiveWindow(t *testing.T) {
+	display := startXvfb(t)
+	client := newEWMHClient(t, display)
+	window := client.createWindow("alacritty", "Alacritty", "vim main.go")
+	client.setPID(window, 4242)
+	client.activate(window)
+
+	x11, err := NewX11ForDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x11.ActiveWindowTitle() != "vim main.go" {
+		t.Fatalf("Unexpected title %q", x11.ActiveWindowTitle())
+	}
+
+	info, err := x11.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != uint64(window) || info.Class != "Alacritty" || info.Instance != "alacritty" || info.PID != 4242 {
+		t.Fatalf("Unexpected window %+v", info)
+	}
+}
+
+func TestX11NoActiveWindow(t *testing.T) {
+	display := startXvfb(t)
+	client := newEWMHClient(t, display)
+	client.deactivate()
+
+	x11, err := NewX11ForDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x11.ActiveWindow(); err != ErrNoActiveWindow {
+		t.Fatalf("Expected no active window, got %v", err)
+	}
+	if x11.ActiveWindowTitle() != "" {
+		t.Fatal("Expected empty title")
+	}
+}
+
+func TestX11DetectWindowTitleChanges(t *testing.T) {
+	display := startXvfb(t)
+	client := newEWMHClient(t, display)
+	first := client.createWindow("alacritty", "Alacritty", "vim main.go")
+	second := client.createWindow("Navigator", "firefox", "Mozilla Firefox")
+
+	x11, err := NewX11ForDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := detectInBackground(t, x11)
+
+	client.activate(first)
+	event := expectEvent(t, events, FocusChanged, first)
+	if event.Title != "vim main.go" {
+		t.Fatalf("Unexpected focus event %+v", event)
+	}
+	client.waitForSubscription(first)
+
+	// the newly active window is now subscribed to, so its retitles show up
+	client.setTitle(first, "vim x11.go")
+	event = expectEvent(t, events, TitleChanged, first)
+	if event.Title != "vim x11.go" {
+		t.Fatalf("Unexpected title event %+v", event)
+	}
+	// and the legacy title set along with it
+	expectEvent(t, events, TitleChanged, first)
+
+	// WM_NAME alone still signals a change, the title comes from _NET_WM_NAME
+	client.setLegacyTitle(first, "legacy")
+	event = expectEvent(t, events, TitleChanged, first)
+	if event.Title != "vim x11.go" {
+		t.Fatalf("Unexpected title event %+v", event)
+	}
+
+	client.activate(second)
+	event = expectEvent(t, events, FocusChanged, second)
+	if event.Title != "Mozilla Firefox" {
+		t.Fatalf("Unexpected focus event %+v", event)
+	}
+	if x11.ActiveWindowTitle() != "Mozilla Firefox" {
+		t.Fatal("Unexpected active title")
+	}
+	client.waitForSubscription(second)
+
+	client.setTitle(second, "GitHub - Mozilla Firefox")
+	expectEvent(t, events, TitleChanged, second)
+
+	client.destroy(second)
+	expectEvent(t, events, WindowClosed, second)
+
+	client.deactivate()
+	expectEvent(t, events, FocusChanged, 0)
+
+	client.switchDesktop(1, "main", "web")
+	event = expectEvent(t, events, WorkspaceChanged, 0)
+	if event.Title != "web" {
+		t.Fatalf("Unexpected workspace event %+v", event)
+	}
+}
+
+func TestX11CurrentWorkspace(t *testing.T) {
+	display := startXvfb(t)
+	client := newEWMHClient(t, display)
+	client.switchDesktop(2, "main", "web")
+
+	x11, err := NewX11ForDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace, err := x11.CurrentWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workspace != "3" {
+		t.Fatalf("Expected unnamed desktop to be numbered, got %s", workspace)
+	}
+}
+
+func TestX11BadDisplayNamed(t *testing.T) {
+	_, err := NewX11ForDisplay(":4242")
+	if err == nil {
+		t.Fatal("Expected error for a display that does not exist")
+	}
+	if !strings.HasPrefix(err.Error(), "X11 display :4242: ") {
+		t.Fatalf("Expected the display to be named, got %v", err)
+	}
+}
+
+func TestX11UrgentWindows(t *testing.T) {
+	display := startXvfb(t)
+	client := newEWMHClient(t, display)
+	editor := client.createWindow("alacritty", "Alacritty", "vim main.go")
+	chat := client.createWindow("slack", "Slack", "chat")
+	mail := client.createWindow("Mail", "thunderbird", "mail")
+	client.setClientList(editor, chat, mail)
+	client.setUrgencyHint(chat, true)
+	client.demandAttention(mail)
+	client.activate(editor)
+
+	x11, err := NewX11ForDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urgent, err := x11.UrgentWindows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urgent) != 2 || urgent[0].ID != uint64(chat) || urgent[0].Title != "chat" || urgent[1].ID != uint64(mail) {
+		t.Fatalf("Unexpected urgent windows %+v", urgent)
+	}
+	info, err := x11.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Urgent {
+		t.Fatal("Expected the active window to be calm")
+	}
+
+	events := detectInBackground(t, x11)
+	// managed windows are subscribed to in the order of the client list
+	client.waitForSubscription(mail)
+
+	client.setUrgencyHint(chat, false)
+	if event := expectEvent(t, events, UrgencyChanged, chat); event.Urgent {
+		t.Fatalf("Expected chat to calm down, got %+v", event)
+	}
+	urgent, err = x11.UrgentWindows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urgent) != 1 || urgent[0].ID != uint64(mail) {
+		t.Fatalf("Expected only mail after the event, got %+v", urgent)
+	}
+	client.setUrgencyHint(editor, true)
+	if event := expectEvent(t, events, UrgencyChanged, editor); !event.Urgent || event.Title != "vim main.go" {
+		t.Fatalf("Expected the editor to demand attention, got %+v", event)
+	}
+}
+
+func TestUrgentCache(t *testing.T) {
+	cache := &urgentCache{}
+	computed := 0
+	compute := func() ([]Info, error) {
+		computed++
+		return []Info{{ID: uint64(computed)}}, nil
+	}
+
+	// nothing is kept until the event loop runs to invalidate it
+	cache.get(compute)
+	cache.get(compute)
+	if computed != 2 {
+		t.Fatalf("Expected every call computed before watching, got %d", computed)
+	}
+
+	cache.watch()
+	cache.get(compute)
+	windows, _ := cache.get(compute)
+	if computed != 3 || windows[0].ID != 3 || !cache.contains(3) {
+		t.Fatalf("Expected one computation while fresh, got %d and %+v", computed, windows)
+	}
+
+	cache.invalidate()
+	windows, _ = cache.get(compute)
+	if computed != 4 || windows[0].ID != 4 {
+		t.Fatalf("Expected a computation after invalidating, got %d and %+v", computed, windows)
+	}
+
+	// an invalidation while computing leaves the result out of the cache
+	cache.invalidate()
+	cache.get(func() ([]Info, error) {
+		cache.invalidate()
+		return compute()
+	})
+	cache.get(compute)
+	if computed != 6 {
+		t.Fatalf("Expected the stale computation thrown away, got %d", computed)
+	}
+}
+
+func TestX11WindowStates(t *testing.T) {
+	display := startXvfb(t)
+	client := newEWMHClient(t, display)
+	window := client.createWindow("mpv", "mpv", "movie.mkv")
+	client.setClientList(window)
+	client.setStates(window, "_NET_WM_STATE_FULLSCREEN", "_NET_WM_STATE_ABOVE")
+	client.activate(window)
+
+	x11, err := NewX11ForDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := x11.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(info.States, " ") != "fullscreen above" {
+		t.Fatalf("Unexpected states %v", info.States)
+	}
+	if len(x11.stateNames.names) != 2 {
+		t.Fatalf("Expected the names of both states cached, got %v", x11.stateNames.names)
+	}
+
+	events := detectInBackground(t, x11)
+	client.waitForSubscription(window)
+
+	client.setStates(window)
+	expectEvent(t, events, StateChanged, window)
+	info, err = x11.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.States) != 0 {
+		t.Fatalf("Expected no states, got %v", info.States)
+	}
+}
+
+func TestX11WindowManager(t *testing.T) {
+	display := startXvfb(t)
+	client := newEWMHClient(t, display)
+
+	x11, err := NewX11ForDisplay(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x11.WindowManager(); err != ErrNoWindowManager {
+		t.Fatalf("Expected no window manager, got %v", err)
+	}
+	if supported, err := x11.SupportedAtoms(); err != nil || len(supported) != 0 {
+		t.Fatalf("Expected no supported atoms, got %v, %v", supported, err)
+	}
+
+	// announce a window manager supporting a couple of hints
+	check := client.createWindow("testwm", "TestWM", "testwm")
+	value := make([]byte, 4)
+	xgb.Put32(value, uint32(check))
+	err = xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, client.root,
+		client.atom("_NET_SUPPORTING_WM_CHECK"), xproto.AtomWindow, 32, 1, value).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	supported := []string{"_NET_ACTIVE_WINDOW", "_NET_WM_NAME"}
+	value = make([]byte, 4*len(supported))
+	for i, name := range supported {
+		xgb.Put32(value[4*i:], uint32(client.atom(name)))
+	}
+	err = xproto.ChangePropertyChecked(client.conn, xproto.PropModeReplace, client.root,
+		client.atom("_NET_SUPPORTED"), xproto.AtomAtom, 32, uint32(len(supported)), value).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := x11.WindowManager()
+	if err != nil || name != "testwm" {
+		t.Fatalf("Expected testwm, got %q, %v", name, err)
+	}
+	names, err := x11.SupportedAtoms()
+	if err != nil || strings.Join(names, ",") != "_NET_ACTIVE_WINDOW,_NET_WM_NAME" {
+		t.Fatalf("Unexpected supported atoms %v, %v", names, err)
+	}
+
+	// the client only interned a few of the atoms NewX11 looks up
+	missing := strings.Join(x11.MissingAtoms(), ",")
+	if strings.Contains(missing, "_NET_ACTIVE_WINDOW") || !strings.Contains(missing, "_NET_WM_PID") {
+		t.Fatalf("Unexpected missing atoms %s", missing)
+	}
+}